@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/cx009/netperf/internal/report"
+)
+
+// CompareConfig holds the settings of "bandfetch compare".
+type CompareConfig struct {
+	BaselinePath string
+	CurrentPath  string
+	Tolerances   report.Tolerances
+}
+
+// ParseCompare parses "bandfetch compare [flags] baseline.json current.json".
+func ParseCompare(fs *flag.FlagSet, args []string) (*CompareConfig, error) {
+	tol := toleranceFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 2 {
+		return nil, errors.New("compare needs a baseline and a current report")
+	}
+	cc := &CompareConfig{
+		BaselinePath: fs.Arg(0),
+		CurrentPath:  fs.Arg(1),
+		Tolerances:   tol.get(),
+	}
+	c := &Config{Tolerances: cc.Tolerances}
+	if err := c.validateTolerances(); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// tolerances holds the flags shared by -baseline runs and compare.
+type tolerances struct {
+	throughput *float64
+	errorRate  *float64
+}
+
+func toleranceFlags(fs *flag.FlagSet) tolerances {
+	return tolerances{
+		throughput: fs.Float64("tolerance", report.DefaultThroughputTolerance, "allowed relative throughput drop against the baseline, e.g. 0.10 for 10%"),
+		errorRate:  fs.Float64("error-tolerance", report.DefaultErrorTolerance, "allowed error rate rise against the baseline, e.g. 0.01 for 1 point"),
+	}
+}
+
+func (t tolerances) get() report.Tolerances {
+	return report.Tolerances{Throughput: *t.throughput, ErrorRate: *t.errorRate}
+}
+
+func (c *Config) validateTolerances() error {
+	if t := c.Tolerances.Throughput; t < 0 || t >= 1 {
+		return c.invalid("tolerance", fmt.Sprintf("-tolerance must be at least 0 and below 1, got %v", t))
+	}
+	if t := c.Tolerances.ErrorRate; t < 0 || t > 1 {
+		return c.invalid("error-tolerance", fmt.Sprintf("-error-tolerance must be between 0 and 1, got %v", t))
+	}
+	return nil
+}
@@ -3,10 +3,18 @@ package config
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/cx009/netperf/internal/downloader"
+	"github.com/cx009/netperf/internal/report"
 )
 
 // Config holds parsed CLI settings.
@@ -18,6 +26,100 @@ type Config struct {
 	Timeout  time.Duration
 	Retries  int
 	Progress bool
+	// ProbeMirrors orders each entry's mirrors by a quick latency probe.
+	ProbeMirrors bool
+	// Headers are added to every request.
+	Headers http.Header
+	// OnConflict and NameTemplate control how saved files are named.
+	OnConflict   downloader.ConflictPolicy
+	NameTemplate string
+	// PreservePaths mirrors the remote host/path layout under OutDir.
+	PreservePaths bool
+	// Incremental revalidates previously saved files instead of refetching.
+	Incremental bool
+	// Archive collects successful downloads into one .tar, .tar.gz or .zip.
+	Archive string
+	// Stdout streams downloads to standard output in list order (-out -).
+	Stdout bool
+	// StallTimeout and LowSpeedLimit/LowSpeedTime abort transfers that
+	// stop or crawl; Timeout only covers the wait for response headers.
+	// StallTimeout uses the downloader.Options meaning, so it can be passed
+	// straight through: -stall-timeout 0 or off stores
+	// downloader.NoStallTimeout.
+	StallTimeout  time.Duration
+	LowSpeedLimit int64
+	LowSpeedTime  time.Duration
+	// Preallocate, Fsync and BufferSize tune the disk write path.
+	Preallocate bool
+	Fsync       bool
+	BufferSize  int
+	// Autoscale grows the worker pool from Workers up to AutoscaleMax while
+	// throughput keeps improving by AutoscaleThreshold per level.
+	Autoscale          bool
+	AutoscaleMax       int
+	AutoscaleInterval  time.Duration
+	AutoscaleThreshold float64
+	// JSONReport, if set, receives the run summary as JSON; Baseline is a
+	// report to compare the run against within Tolerances.
+	JSONReport string
+	Baseline   string
+	Tolerances report.Tolerances
+	// HTMLReport, if set, receives a self-contained HTML report.
+	HTMLReport string
+	// JUnit, if set, receives a JUnit XML report with one test case per
+	// URL; JUnitMinBps additionally fails downloads slower than it.
+	JUnit       string
+	JUnitMinBps float64
+	// FailFast, MaxFailures and MaxFailureRate cancel the run once too
+	// many downloads fail; zero values disable them.
+	FailFast       bool
+	MaxFailures    int
+	MaxFailureRate float64
+	// Thresholds are asserted against the final summary.
+	Thresholds report.Thresholds
+	// HistoryDir, if set, gets a record of every run; Label tags it.
+	HistoryDir string
+	Label      string
+
+	// ConfigPath and Profile record the config file selection, if any.
+	ConfigPath string
+	Profile    string
+	// PrintConfig asks the caller to dump the effective configuration.
+	PrintConfig bool
+
+	// sources maps flag names to where their value came from.
+	sources map[string]string
+}
+
+// Source describes where the named setting came from, e.g. "flag -workers"
+// or "config file ci.json (profile nightly)". Unset settings are "default".
+func (c *Config) Source(name string) string {
+	if s, ok := c.sources[name]; ok {
+		return s
+	}
+	return "default"
+}
+
+// Copy buffer bounds for -buffer-size.
+const (
+	minBufferSize = 4 << 10
+	maxBufferSize = 256 << 20
+)
+
+// StatusOutput is where progress, [OK]/[FAIL] lines and the summary belong:
+// stderr when downloads are streamed to stdout, stdout otherwise.
+func (c *Config) StatusOutput() io.Writer {
+	if c.Stdout {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// NeedsSampler reports whether the caller must start metrics.StartSampler:
+// -min-p10 is computed from per-second samples, which the progress printer
+// records only when -progress is on.
+func (c *Config) NeedsSampler() bool {
+	return c.Thresholds.MinP10Bps > 0 && !c.Progress
 }
 
 // DefaultWorkers returns the default worker count based on CPU cores.
@@ -32,19 +134,63 @@ func DefaultWorkers() int {
 	return w
 }
 
+// invalid builds a validation error that names the value's origin when it
+// did not come from the built-in defaults.
+func (c *Config) invalid(name, msg string) error {
+	src := c.Source(name)
+	if src == "default" {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%s (from %s)", msg, src)
+}
+
 // Normalize validates and fills derived values.
 func (c *Config) Normalize() error {
-	if c.ListPath == "" {
-		return errors.New("-list is required")
+	// -print-config only reports the settings, so it needs no URL list.
+	if c.ListPath == "" && !c.PrintConfig {
+		return c.invalid("list", "-list is required")
 	}
 	if c.Workers <= 0 {
 		c.Workers = DefaultWorkers()
+		if c.Autoscale {
+			c.Workers = 1
+		}
 	}
 	if c.Timeout <= 0 {
-		return errors.New("-timeout must be greater than 0")
+		return c.invalid("timeout", "-timeout must be greater than 0")
 	}
 	if c.Retries < 0 {
-		return errors.New("-retries cannot be negative")
+		return c.invalid("retries", "-retries cannot be negative")
+	}
+
+	policy, err := downloader.ParseConflictPolicy(string(c.OnConflict))
+	if err != nil {
+		return c.invalid("on-conflict", err.Error())
+	}
+	c.OnConflict = policy
+	if err := downloader.ValidateNameTemplate(c.NameTemplate); err != nil {
+		return c.invalid("name-template", err.Error())
+	}
+	if c.PreservePaths && c.NameTemplate != "" {
+		return c.invalid("preserve-paths", "-preserve-paths cannot be combined with -name-template")
+	}
+
+	if strings.TrimSpace(c.OutDir) == "-" {
+		if c.Archive != "" || c.Incremental {
+			return c.invalid("out", "-out - cannot be combined with -archive or -incremental")
+		}
+		c.Stdout = true
+		c.Save = false
+		c.OutDir = ""
+	}
+
+	if c.Archive != "" {
+		if _, err := downloader.ArchiveFormatFor(c.Archive); err != nil {
+			return c.invalid("archive", err.Error())
+		}
+		if c.Save || strings.TrimSpace(c.OutDir) != "" || c.Incremental {
+			return c.invalid("archive", "-archive cannot be combined with -save, -out or -incremental")
+		}
 	}
 
 	if strings.TrimSpace(c.OutDir) != "" {
@@ -58,31 +204,191 @@ func (c *Config) Normalize() error {
 		c.OutDir = "downloads"
 	}
 
+	if c.BufferSize == 0 {
+		c.BufferSize = downloader.DefaultBufferSize
+	}
+	if c.BufferSize < minBufferSize || c.BufferSize > maxBufferSize {
+		return c.invalid("buffer-size", fmt.Sprintf("-buffer-size must be between %d and %d bytes", minBufferSize, maxBufferSize))
+	}
+
+	if c.LowSpeedLimit > 0 && c.LowSpeedTime <= 0 {
+		return c.invalid("low-speed-time", "-low-speed-time must be greater than 0 with -low-speed-limit")
+	}
+
+	if c.Incremental && !c.Save {
+		return c.invalid("incremental", "-incremental requires -save or -out")
+	}
+
+	if err := c.validateTolerances(); err != nil {
+		return err
+	}
+	if c.JUnitMinBps > 0 && c.JUnit == "" {
+		return c.invalid("junit-min-throughput", "-junit-min-throughput requires -junit")
+	}
+
+	if c.MaxFailures < 0 {
+		return c.invalid("max-failures", "-max-failures must not be negative")
+	}
+	if c.MaxFailureRate > 1 {
+		return c.invalid("max-failure-rate", fmt.Sprintf("-max-failure-rate must be at most 100%%, got %v%%", c.MaxFailureRate*100))
+	}
+
+	if r := c.Thresholds.MaxErrorRate; r != nil && *r > 1 {
+		return c.invalid("max-error-rate", fmt.Sprintf("-max-error-rate must be at most 100%%, got %v%%", *r*100))
+	}
+	if c.Thresholds.MaxTTFBP95 < 0 {
+		return c.invalid("max-ttfb-p95", "-max-ttfb-p95 must not be negative")
+	}
+
+	if c.Autoscale {
+		if c.AutoscaleMax < c.Workers {
+			return c.invalid("autoscale-max", fmt.Sprintf("-autoscale-max must be at least the starting -workers (%d)", c.Workers))
+		}
+		if c.AutoscaleInterval < downloader.MinAutoscaleInterval {
+			return c.invalid("autoscale-interval", fmt.Sprintf("-autoscale-interval must be at least %v", downloader.MinAutoscaleInterval))
+		}
+		if c.AutoscaleThreshold <= 0 || c.AutoscaleThreshold >= 1 {
+			return c.invalid("autoscale-threshold", "-autoscale-threshold must be between 0 and 1")
+		}
+	}
+
 	return nil
 }
 
 // Parse uses the provided FlagSet to load configuration from flags.
+// Settings not given on the command line fall back to BANDFETCH_*
+// environment variables, then to the -config file (the -profile section
+// over its top-level keys), then to defaults.
 func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
 	list := fs.String("list", "", "path to URL list (required)")
 	save := fs.Bool("save", false, "persist downloads to disk (default discards)")
-	out := fs.String("out", "", "directory for downloads; implies -save when set")
+	out := fs.String("out", "", "directory for downloads (implies -save), or - to stream to stdout")
 	workers := fs.Int("workers", 0, "number of concurrent download workers")
-	timeout := fs.Duration("timeout", 60*time.Second, "per-request timeout (e.g. 45s, 2m)")
+	timeout := fs.Duration("timeout", 60*time.Second, "time allowed for the TLS handshake and response headers (e.g. 45s, 2m)")
+	stallTimeout := stallFlag(downloader.DefaultStallTimeout)
+	fs.Var(&stallTimeout, "stall-timeout", "abort an attempt that receives no data for this long (0 or off disables)")
+	lowSpeedLimit := byteSizeFlag(0)
+	fs.Var(&lowSpeedLimit, "low-speed-limit", "abort an attempt slower than this many bytes/s for -low-speed-time, e.g. 64K (0 disables)")
+	lowSpeedTime := fs.Duration("low-speed-time", downloader.DefaultLowSpeedTime, "how long an attempt may stay below -low-speed-limit")
 	retries := fs.Int("retries", 3, "retry attempts beyond the first request")
 	progress := fs.Bool("progress", true, "enable live bandwidth output")
+	probeMirrors := fs.Bool("probe-mirrors", false, "probe mirror latency and try the fastest mirror first")
+	onConflict := fs.String("on-conflict", "overwrite", "when a saved file exists: overwrite, skip, rename or fail")
+	nameTemplate := fs.String("name-template", "", "layout for saved files, e.g. {host}/{path} or {index}-{basename}")
+	preservePaths := fs.Bool("preserve-paths", false, "recreate the remote host/path directory layout under -out")
+	incremental := fs.Bool("incremental", false, "skip unchanged files using a manifest of ETag/Last-Modified/size in -out")
+	archive := fs.String("archive", "", "write successful downloads into a single .tar, .tar.gz or .zip file")
+	preallocate := fs.Bool("preallocate", false, "reserve Content-Length bytes before writing (fallocate on Linux)")
+	fsync := fs.Bool("fsync", false, "fsync files before renaming them into place")
+	bufferSize := byteSizeFlag(downloader.DefaultBufferSize)
+	fs.Var(&bufferSize, "buffer-size", "copy buffer size, e.g. 256K or 4M")
+	autoscale := fs.Bool("autoscale", false, "grow workers from -workers (default 1) until throughput saturates")
+	autoscaleMax := fs.Int("autoscale-max", downloader.DefaultAutoscaleMax, "worker ceiling for -autoscale")
+	autoscaleInterval := fs.Duration("autoscale-interval", downloader.DefaultAutoscaleInterval, "how long -autoscale measures each worker level")
+	autoscaleThreshold := fs.Float64("autoscale-threshold", downloader.DefaultAutoscaleThreshold, "relative throughput gain needed to keep growing, e.g. 0.05 for 5%")
+	jsonReport := fs.String("json-report", "", "write the run summary as JSON to this file")
+	htmlReport := fs.String("html-report", "", "write a self-contained HTML report with charts to this file")
+	junit := fs.String("junit", "", "write a JUnit XML report with one test case per URL to this file")
+	var junitMin bitRateFlag
+	fs.Var(&junitMin, "junit-min-throughput", "fail -junit test cases slower than this, e.g. 50Mbit")
+	baseline := fs.String("baseline", "", "compare the run against a saved -json-report and fail on regression")
+	tol := toleranceFlags(fs)
+	failFast := fs.Bool("fail-fast", false, "cancel the run on the first download that fails after all retries")
+	maxFailures := fs.Int("max-failures", 0, "cancel the run once more than this many downloads fail (0 = no limit)")
+	var maxFailureRate ratioFlag
+	fs.Var(&maxFailureRate, "max-failure-rate", fmt.Sprintf("cancel the run once more than this share of downloads fail, e.g. 20%% (checked after %d)", downloader.DefaultFailureRateMinFiles))
+	var minAvg, minP10 bitRateFlag
+	fs.Var(&minAvg, "min-avg", "fail the run if average throughput is below this, e.g. 500Mbit")
+	fs.Var(&minP10, "min-p10", "fail the run if 10th percentile throughput is below this")
+	var maxErrorRate ratioFlag
+	fs.Var(&maxErrorRate, "max-error-rate", "fail the run if more downloads than this fail, e.g. 1%")
+	maxTTFB := fs.Duration("max-ttfb-p95", 0, "fail the run if the 95th percentile time to first byte exceeds this")
+	historyDir := fs.String("history-dir", "", "append a record of every run to history.jsonl in this directory")
+	label := fs.String("label", "", "free-form label stored with the run in -history-dir")
+	headers := headerFlag{}
+	fs.Var(headers, "header", "extra request header \"Name: value\" (repeatable)")
+	configPath := fs.String("config", "", "JSON config file with settings and named profiles")
+	profile := fs.String("profile", "", "profile to select from -config")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and the source of each value")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
 
+	sources := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = "flag -" + f.Name
+	})
+	if err := applyEnv(fs, sources); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		values, selected, err := loadFile(*configPath, *profile)
+		if err != nil {
+			return nil, err
+		}
+		*profile = selected
+		origin := "config file " + *configPath
+		if selected != "" {
+			origin += " (profile " + selected + ")"
+		}
+		if err := applyLayer(fs, sources, values, origin); err != nil {
+			return nil, err
+		}
+	} else if *profile != "" {
+		return nil, errors.New("-profile requires -config")
+	}
+
 	cfg := &Config{
-		ListPath: *list,
-		Save:     *save,
-		OutDir:   *out,
-		Workers:  *workers,
-		Timeout:  *timeout,
-		Retries:  *retries,
-		Progress: *progress,
+		ListPath:      *list,
+		Save:          *save,
+		OutDir:        *out,
+		Workers:       *workers,
+		Timeout:       *timeout,
+		Retries:       *retries,
+		StallTimeout:  time.Duration(stallTimeout),
+		LowSpeedLimit: int64(lowSpeedLimit),
+		LowSpeedTime:  *lowSpeedTime,
+		Progress:      *progress,
+		ProbeMirrors:  *probeMirrors,
+		Headers:       http.Header(headers),
+		OnConflict:    downloader.ConflictPolicy(*onConflict),
+		NameTemplate:  *nameTemplate,
+		PreservePaths: *preservePaths,
+		Incremental:   *incremental,
+		Archive:       *archive,
+		Preallocate:   *preallocate,
+		Fsync:         *fsync,
+		BufferSize:    int(bufferSize),
+
+		Autoscale:          *autoscale,
+		AutoscaleMax:       *autoscaleMax,
+		AutoscaleInterval:  *autoscaleInterval,
+		AutoscaleThreshold: *autoscaleThreshold,
+
+		JSONReport:     *jsonReport,
+		Baseline:       *baseline,
+		Tolerances:     tol.get(),
+		HTMLReport:     *htmlReport,
+		JUnit:          *junit,
+		JUnitMinBps:    float64(junitMin),
+		FailFast:       *failFast,
+		MaxFailures:    *maxFailures,
+		MaxFailureRate: maxFailureRate.get(),
+		Thresholds: report.Thresholds{
+			MinAvgBps:    float64(minAvg),
+			MinP10Bps:    float64(minP10),
+			MaxErrorRate: maxErrorRate.v,
+			MaxTTFBP95:   *maxTTFB,
+		},
+		HistoryDir: *historyDir,
+		Label:      *label,
+
+		ConfigPath:  *configPath,
+		Profile:     *profile,
+		PrintConfig: *printConfig,
+		sources:     sources,
 	}
 
 	if err := cfg.Normalize(); err != nil {
@@ -90,3 +396,86 @@ func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
 	}
 	return cfg, nil
 }
+
+// applyLayer sets flags that no higher-precedence layer has claimed yet and
+// records origin as their source.
+func applyLayer(fs *flag.FlagSet, sources map[string]string, values map[string][]string, origin string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vals := values[name]
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", origin, name)
+		}
+		if _, taken := sources[name]; taken {
+			continue
+		}
+		for _, v := range vals {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: invalid value %q for %s: %v", origin, v, name, err)
+			}
+		}
+		sources[name] = origin
+	}
+	return nil
+}
+
+// stallFlag is a flag.Value for -stall-timeout: a duration, or "0"/"off"
+// for downloader.NoStallTimeout.
+type stallFlag time.Duration
+
+func (s *stallFlag) String() string { return stallString(time.Duration(*s)) }
+
+func (s *stallFlag) Set(v string) error {
+	v = strings.TrimSpace(v)
+	if v == "0" || strings.EqualFold(v, "off") {
+		*s = stallFlag(downloader.NoStallTimeout)
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return errors.New("must not be negative")
+	}
+	if d == 0 {
+		d = downloader.NoStallTimeout
+	}
+	*s = stallFlag(d)
+	return nil
+}
+
+// stallString renders a stall timeout, showing "off" when disabled.
+func stallString(d time.Duration) string {
+	if d < 0 {
+		return "off"
+	}
+	return d.String()
+}
+
+// headerFlag collects repeated -header "Name: value" flags.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var parts []string
+	for k, vs := range h {
+		for _, v := range vs {
+			parts = append(parts, k+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h headerFlag) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("header %q must be \"Name: value\"", v)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(value))
+	return nil
+}
@@ -2,8 +2,13 @@ package config
 
 import (
 	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/cx009/netperf/internal/downloader"
 )
 
 func TestNormalizeDefaults(t *testing.T) {
@@ -62,3 +67,371 @@ func TestParseMissingList(t *testing.T) {
 		t.Fatalf("expected error for missing list flag")
 	}
 }
+
+func TestParsePrintConfigWithoutList(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-print-config"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.PrintConfig {
+		t.Fatalf("expected PrintConfig to be set")
+	}
+}
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bandfetch.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+const profileConfig = `{
+  "list": "urls.txt",
+  "workers": 2,
+  "default_profile": "ci",
+  "profiles": {
+    "ci":   {"timeout": "30s", "retries": 1, "headers": {"X-Test": "ci"}},
+    "soak": {"workers": 64, "out": "soak-out", "timeout": "0s"}
+  }
+}`
+
+func TestParseConfigFileProfile(t *testing.T) {
+	path := writeConfig(t, profileConfig)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-config", path, "-retries", "5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Profile != "ci" {
+		t.Fatalf("expected default profile ci, got %q", cfg.Profile)
+	}
+	if cfg.Workers != 2 || cfg.Timeout != 30*time.Second || cfg.ListPath != "urls.txt" {
+		t.Fatalf("unexpected merged config: %+v", cfg)
+	}
+	if cfg.Retries != 5 || cfg.Source("retries") != "flag -retries" {
+		t.Fatalf("expected flag to override file, got %d from %s", cfg.Retries, cfg.Source("retries"))
+	}
+	if got := cfg.Headers.Get("X-Test"); got != "ci" {
+		t.Fatalf("expected header from profile, got %q", got)
+	}
+}
+
+func TestParseConfigFileReportsSource(t *testing.T) {
+	path := writeConfig(t, profileConfig)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := Parse(fs, []string{"-config", path, "-profile", "soak"})
+	if err == nil {
+		t.Fatalf("expected invalid timeout from profile")
+	}
+	if !strings.Contains(err.Error(), "profile soak") {
+		t.Fatalf("expected error to name the profile, got %v", err)
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown profile": profileConfig,
+		"unknown key":     `{"list": "urls.txt", "wrokers": 3}`,
+		"bad value":       `{"list": "urls.txt", "workers": "many"}`,
+	}
+	for name, body := range cases {
+		path := writeConfig(t, body)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		args := []string{"-config", path}
+		if name == "unknown profile" {
+			args = append(args, "-profile", "missing")
+		}
+		if _, err := Parse(fs, args); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseEnvPrecedence(t *testing.T) {
+	path := writeConfig(t, `{"list": "file.txt", "workers": 2, "retries": 7}`)
+	t.Setenv("BANDFETCH_WORKERS", "12")
+	t.Setenv("BANDFETCH_LIST", "env.txt")
+	t.Setenv("BANDFETCH_CONFIG", path)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "flag.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ListPath != "flag.txt" {
+		t.Fatalf("expected flag to beat env, got %s", cfg.ListPath)
+	}
+	if cfg.Workers != 12 || cfg.Source("workers") != "env BANDFETCH_WORKERS" {
+		t.Fatalf("expected env to beat file, got %d from %s", cfg.Workers, cfg.Source("workers"))
+	}
+	if cfg.Retries != 7 {
+		t.Fatalf("expected file to beat default, got %d", cfg.Retries)
+	}
+	if cfg.Source("timeout") != "default" {
+		t.Fatalf("expected timeout from defaults, got %s", cfg.Source("timeout"))
+	}
+}
+
+func TestParseEnvInvalidNamesVariable(t *testing.T) {
+	t.Setenv("BANDFETCH_TIMEOUT", "soon")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := Parse(fs, []string{"-list", "urls.txt"})
+	if err == nil || !strings.Contains(err.Error(), "BANDFETCH_TIMEOUT") {
+		t.Fatalf("expected error naming BANDFETCH_TIMEOUT, got %v", err)
+	}
+}
+
+func TestPrintEffective(t *testing.T) {
+	t.Setenv("BANDFETCH_RETRIES", "4")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "urls.txt", "-print-config", "-header", "Authorization: secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b strings.Builder
+	if err := cfg.PrintEffective(&b); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{"(flag -list)", "(env BANDFETCH_RETRIES)", "Authorization: ***"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Fatalf("header value leaked:\n%s", out)
+	}
+}
+
+func TestNormalizeStdout(t *testing.T) {
+	cfg := &Config{ListPath: "urls.txt", OutDir: "-", Save: true, Timeout: time.Second}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Stdout || cfg.Save || cfg.OutDir != "" {
+		t.Fatalf("expected stdout mode without saving, got %+v", cfg)
+	}
+	if cfg.StatusOutput() != os.Stderr {
+		t.Fatalf("expected status output on stderr in stdout mode")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{
+		"65536": 65536,
+		"64K":   64 << 10,
+		"4MiB":  4 << 20,
+		"1gb":   1 << 30,
+	}
+	for in, want := range cases {
+		got, err := ParseByteSize(in)
+		if err != nil || got != want {
+			t.Fatalf("%q: expected %d, got %d (%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"lots", "9999999999999G", "9223372036854775807K"} {
+		if _, err := ParseByteSize(in); err == nil {
+			t.Fatalf("%q: expected error for invalid size", in)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-buffer-size", "1K"}); err == nil {
+		t.Fatalf("expected buffer below the minimum to be rejected")
+	}
+}
+
+func TestParseAutoscale(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "urls.txt", "-autoscale", "-autoscale-max", "32"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Workers != 1 || cfg.AutoscaleMax != 32 {
+		t.Fatalf("expected to start at 1 worker up to 32, got %d/%d", cfg.Workers, cfg.AutoscaleMax)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-autoscale", "-workers", "8", "-autoscale-max", "4"}); err == nil {
+		t.Fatalf("expected error for ceiling below starting workers")
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-autoscale", "-autoscale-interval", "3ns"}); err == nil {
+		t.Fatalf("expected error for an interval too short to sample")
+	}
+}
+
+func TestParseSweep(t *testing.T) {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	cfg, err := ParseSweep(fs, []string{"-list", "urls.txt", "-sweep-workers", "1..8", "-protocols", "h1,h2", "-step", "5s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.SweepWorkers) != 4 || len(cfg.Protocols) != 2 || cfg.StepDuration != 5*time.Second {
+		t.Fatalf("unexpected sweep config: %+v", cfg)
+	}
+
+	fs = flag.NewFlagSet("sweep", flag.ContinueOnError)
+	if _, err := ParseSweep(fs, []string{"-list", "urls.txt", "-save"}); err == nil {
+		t.Fatalf("expected sweep to reject -save")
+	}
+}
+
+func TestParseCompare(t *testing.T) {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	cfg, err := ParseCompare(fs, []string{"-tolerance", "0.2", "base.json", "cur.json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BaselinePath != "base.json" || cfg.CurrentPath != "cur.json" || cfg.Tolerances.Throughput != 0.2 {
+		t.Fatalf("unexpected compare config: %+v", cfg)
+	}
+
+	fs = flag.NewFlagSet("compare", flag.ContinueOnError)
+	if _, err := ParseCompare(fs, []string{"base.json"}); err == nil {
+		t.Fatalf("expected error for a missing report")
+	}
+	fs = flag.NewFlagSet("compare", flag.ContinueOnError)
+	if _, err := ParseCompare(fs, []string{"-tolerance", "1.5", "a", "b"}); err == nil {
+		t.Fatalf("expected error for an out-of-range tolerance")
+	}
+}
+
+func TestParseHistoryDirFromEnv(t *testing.T) {
+	t.Setenv("BANDFETCH_HISTORY_DIR", "/var/lib/bandfetch")
+	t.Setenv("BANDFETCH_LIST", "urls.txt")
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	cfg, err := ParseHistory(fs, []string{"-trends", "-by", "host"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Dir != "/var/lib/bandfetch" || !cfg.Trends || cfg.By != "host" {
+		t.Fatalf("unexpected history config: %+v", cfg)
+	}
+	if cfg.Filter.List != "" {
+		t.Fatalf("BANDFETCH_LIST must not become a history filter")
+	}
+}
+
+func TestParseBitRate(t *testing.T) {
+	tests := map[string]float64{
+		"2000000":    2e6,
+		"800k":       800e3,
+		"500Mbit":    500e6,
+		"1.5Gbit/s":  1.5e9,
+		"100 Mbps":   100e6,
+		"250.00Mbit": 250e6,
+	}
+	for in, want := range tests {
+		got, err := ParseBitRate(in)
+		if err != nil || got != want {
+			t.Errorf("ParseBitRate(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "fast", "-1M", "NaN", "Inf", "+infM", "1e308G"} {
+		if _, err := ParseBitRate(bad); err == nil {
+			t.Errorf("ParseBitRate(%q): expected error", bad)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "urls.txt", "-junit", "out.xml", "-junit-min-throughput", "50Mbit"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.JUnitMinBps != 50e6 {
+		t.Fatalf("expected 50 Mbit/s, got %v", cfg.JUnitMinBps)
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "urls.txt", "-min-avg", "500Mbit", "-min-p10", "100M", "-max-error-rate", "1%", "-max-ttfb-p95", "200ms"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	th := cfg.Thresholds
+	if th.MinAvgBps != 500e6 || th.MinP10Bps != 100e6 || th.MaxTTFBP95 != 200*time.Millisecond {
+		t.Fatalf("unexpected thresholds: %+v", th)
+	}
+	if th.MaxErrorRate == nil || *th.MaxErrorRate != 0.01 {
+		t.Fatalf("expected a 1%% error rate limit, got %v", th.MaxErrorRate)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err = Parse(fs, []string{"-list", "urls.txt", "-max-error-rate", "0"})
+	if err != nil || cfg.Thresholds.MaxErrorRate == nil || *cfg.Thresholds.MaxErrorRate != 0 {
+		t.Fatalf("expected an explicit zero error rate limit, got %v (%v)", cfg.Thresholds.MaxErrorRate, err)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-max-error-rate", "150%"}); err == nil {
+		t.Fatalf("expected an error rate above 100%% to be rejected")
+	}
+	for _, bad := range []string{"NaN", "Inf%", "-1%"} {
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		if _, err := Parse(fs, []string{"-list", "urls.txt", "-max-error-rate", bad}); err == nil {
+			t.Fatalf("expected error rate %q to be rejected", bad)
+		}
+	}
+
+	if cfg.NeedsSampler() {
+		t.Fatalf("expected the progress printer to supply samples")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err = Parse(fs, []string{"-list", "urls.txt", "-min-p10", "100M", "-progress=false"})
+	if err != nil || !cfg.NeedsSampler() {
+		t.Fatalf("expected -min-p10 without progress to need the sampler (%v)", err)
+	}
+}
+
+func TestParseFailureBudget(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "urls.txt", "-fail-fast", "-max-failures", "10", "-max-failure-rate", "20%"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.FailFast || cfg.MaxFailures != 10 || cfg.MaxFailureRate != 0.2 {
+		t.Fatalf("unexpected failure budget: %v %v %v", cfg.FailFast, cfg.MaxFailures, cfg.MaxFailureRate)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-max-failures", "-1"}); err == nil {
+		t.Fatalf("expected a negative -max-failures to be rejected")
+	}
+}
+
+func TestParseStallLimits(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-list", "urls.txt", "-stall-timeout", "10s", "-low-speed-limit", "64K"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StallTimeout != 10*time.Second || cfg.LowSpeedLimit != 64<<10 || cfg.LowSpeedTime != 30*time.Second {
+		t.Fatalf("unexpected limits: %v %v %v", cfg.StallTimeout, cfg.LowSpeedLimit, cfg.LowSpeedTime)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-low-speed-limit", "1K", "-low-speed-time", "0"}); err == nil {
+		t.Fatalf("expected -low-speed-limit without a window to be rejected")
+	}
+
+	for _, off := range []string{"0", "0s", "off"} {
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg, err = Parse(fs, []string{"-list", "urls.txt", "-stall-timeout", off})
+		if err != nil || cfg.StallTimeout != downloader.NoStallTimeout {
+			t.Fatalf("%s: expected downloader.NoStallTimeout, got %v (%v)", off, cfg.StallTimeout, err)
+		}
+		if got := cfg.Effective()["stall-timeout"]; got != "off" {
+			t.Fatalf("%s: expected the effective value \"off\", got %q", off, got)
+		}
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := Parse(fs, []string{"-list", "urls.txt", "-stall-timeout", "-5s"}); err == nil {
+		t.Fatalf("expected a negative stall timeout to be rejected")
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variable for every flag, e.g.
+// BANDFETCH_WORKERS for -workers and BANDFETCH_PROBE_MIRRORS for
+// -probe-mirrors.
+const EnvPrefix = "BANDFETCH_"
+
+// EnvName returns the environment variable consulted for a flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag not given on the command line from its
+// environment variable, if present.
+func applyEnv(fs *flag.FlagSet, sources map[string]string) error {
+	var firstErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if firstErr != nil {
+			return
+		}
+		if _, taken := sources[f.Name]; taken {
+			return
+		}
+		name := EnvName(f.Name)
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, val); err != nil {
+			firstErr = fmt.Errorf("%s: invalid value %q for -%s: %v", name, val, f.Name, err)
+			return
+		}
+		sources[f.Name] = "env " + name
+	})
+	return firstErr
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileConfig is the on-disk JSON layout:
+//
+//	{
+//	  "workers": 8,
+//	  "default_profile": "ci",
+//	  "profiles": {
+//	    "ci":   {"list": "urls.txt", "timeout": "30s", "retries": 1},
+//	    "soak": {"workers": 64, "headers": {"Authorization": "Bearer x"}}
+//	  }
+//	}
+//
+// Top-level keys apply to every profile; keys use the flag names, plus the
+// aliases in fileAliases.
+type fileConfig struct {
+	DefaultProfile string
+	Base           map[string]json.RawMessage
+	Profiles       map[string]map[string]json.RawMessage
+}
+
+// fileAliases maps friendlier file keys onto flag names.
+var fileAliases = map[string]string{
+	"headers": "header",
+}
+
+// loadFile reads a config file and returns the flag values for the selected
+// profile, already rendered as flag strings. Values with several entries
+// (headers) are returned in the order they should be applied.
+func loadFile(path, profile string) (map[string][]string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, "", fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	fc := fileConfig{Base: map[string]json.RawMessage{}}
+	for key, val := range raw {
+		switch key {
+		case "default_profile":
+			if err := json.Unmarshal(val, &fc.DefaultProfile); err != nil {
+				return nil, "", fmt.Errorf("config file %s: default_profile: %w", path, err)
+			}
+		case "profiles":
+			if err := json.Unmarshal(val, &fc.Profiles); err != nil {
+				return nil, "", fmt.Errorf("config file %s: profiles: %w", path, err)
+			}
+		default:
+			fc.Base[key] = val
+		}
+	}
+
+	if profile == "" {
+		profile = fc.DefaultProfile
+	}
+	merged := make(map[string]json.RawMessage, len(fc.Base))
+	for k, v := range fc.Base {
+		merged[k] = v
+	}
+	if profile != "" {
+		p, ok := fc.Profiles[profile]
+		if !ok {
+			return nil, "", fmt.Errorf("config file %s: profile %q not found (have %s)", path, profile, strings.Join(profileNames(fc.Profiles), ", "))
+		}
+		for k, v := range p {
+			merged[k] = v
+		}
+	}
+
+	out := make(map[string][]string, len(merged))
+	for key, val := range merged {
+		if key == "config" || key == "profile" {
+			return nil, "", fmt.Errorf("config file %s: %q cannot be set from a config file", path, key)
+		}
+		vals, err := flagStrings(val)
+		if err != nil {
+			return nil, "", fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+		if alias, ok := fileAliases[key]; ok {
+			key = alias
+		}
+		out[key] = vals
+	}
+	return out, profile, nil
+}
+
+// flagStrings renders a JSON value the way it would be typed on the command
+// line. Objects become "Key: Value" pairs and arrays repeat the flag.
+func flagStrings(raw json.RawMessage) ([]string, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case string:
+		return []string{t}, nil
+	case bool:
+		return []string{strconv.FormatBool(t)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(t, 'f', -1, 64)}, nil
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("array items must be strings")
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]string, 0, len(t))
+		for _, k := range keys {
+			s, ok := t[k].(string)
+			if !ok {
+				return nil, fmt.Errorf("value for %q must be a string", k)
+			}
+			out = append(out, k+": "+s)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %s", string(raw))
+	}
+}
+
+func profileNames(profiles map[string]map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return []string{"none"}
+	}
+	return names
+}
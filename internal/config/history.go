@@ -0,0 +1,65 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/cx009/netperf/internal/history"
+)
+
+// HistoryConfig holds the settings of "bandfetch history".
+type HistoryConfig struct {
+	Dir    string
+	Filter history.Filter
+	// Trends prints per-group trends instead of listing runs.
+	Trends bool
+	By     history.GroupBy
+	Window int
+	// Limit keeps only the most recent runs in the listing (0 for all).
+	Limit int
+}
+
+// ParseHistory parses "bandfetch history". -history-dir may also come from
+// BANDFETCH_HISTORY_DIR so it only needs to be set once.
+func ParseHistory(fs *flag.FlagSet, args []string) (*HistoryConfig, error) {
+	dir := fs.String("history-dir", "", "directory holding history.jsonl")
+	label := fs.String("label", "", "only runs with this label")
+	profile := fs.String("profile", "", "only runs made with this config profile")
+	list := fs.String("list", "", "only runs of this URL list")
+	host := fs.String("host", "", "only runs made from this machine")
+	trends := fs.Bool("trends", false, "print trends per URL list or host instead of listing runs")
+	by := fs.String("by", "list", "group trends by list or host")
+	window := fs.Int("window", history.DefaultWindow, "moving average length in runs")
+	limit := fs.Int("limit", 20, "show only the most recent runs (0 for all)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *dir == "" {
+		// Only the directory falls back to the environment: BANDFETCH_LIST
+		// and friends configure runs and would silently become filters.
+		*dir = os.Getenv(EnvName("history-dir"))
+	}
+	if *dir == "" {
+		return nil, errors.New("-history-dir (or " + EnvName("history-dir") + ") is required")
+	}
+	group, err := history.ParseGroupBy(*by)
+	if err != nil {
+		return nil, err
+	}
+	if *window < 1 {
+		return nil, errors.New("-window must be at least 1")
+	}
+	if *limit < 0 {
+		return nil, errors.New("-limit cannot be negative")
+	}
+	return &HistoryConfig{
+		Dir:    *dir,
+		Filter: history.Filter{Label: *label, Profile: *profile, List: *list, Host: *host},
+		Trends: *trends,
+		By:     group,
+		Window: *window,
+		Limit:  *limit,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// PrintEffective writes the effective configuration, one setting per line,
+// alongside the layer each value came from.
+func (c *Config) PrintEffective(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range c.settings() {
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", s.name, s.value, c.Source(s.name))
+	}
+	return tw.Flush()
+}
+
+// Effective returns the effective settings by flag name, with header
+// values masked as in PrintEffective, e.g. for run history records.
+func (c *Config) Effective() map[string]string {
+	out := map[string]string{}
+	for _, s := range c.settings() {
+		out[s.name] = s.value
+	}
+	return out
+}
+
+type setting struct {
+	name  string
+	value string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"list", c.ListPath},
+		{"save", fmt.Sprint(c.Save)},
+		{"out", c.outString()},
+		{"workers", fmt.Sprint(c.Workers)},
+		{"timeout", c.Timeout.String()},
+		{"retries", fmt.Sprint(c.Retries)},
+		{"stall-timeout", stallString(c.StallTimeout)},
+		{"low-speed-limit", fmt.Sprint(c.LowSpeedLimit)},
+		{"low-speed-time", c.LowSpeedTime.String()},
+		{"progress", fmt.Sprint(c.Progress)},
+		{"probe-mirrors", fmt.Sprint(c.ProbeMirrors)},
+		{"header", c.headerString()},
+		{"on-conflict", string(c.OnConflict)},
+		{"name-template", c.NameTemplate},
+		{"preserve-paths", fmt.Sprint(c.PreservePaths)},
+		{"incremental", fmt.Sprint(c.Incremental)},
+		{"archive", c.Archive},
+		{"preallocate", fmt.Sprint(c.Preallocate)},
+		{"fsync", fmt.Sprint(c.Fsync)},
+		{"buffer-size", fmt.Sprint(c.BufferSize)},
+		{"autoscale", fmt.Sprint(c.Autoscale)},
+		{"autoscale-max", fmt.Sprint(c.AutoscaleMax)},
+		{"autoscale-interval", c.AutoscaleInterval.String()},
+		{"autoscale-threshold", fmt.Sprint(c.AutoscaleThreshold)},
+		{"json-report", c.JSONReport},
+		{"html-report", c.HTMLReport},
+		{"junit", c.JUnit},
+		{"junit-min-throughput", fmt.Sprint(c.JUnitMinBps)},
+		{"baseline", c.Baseline},
+		{"tolerance", fmt.Sprint(c.Tolerances.Throughput)},
+		{"error-tolerance", fmt.Sprint(c.Tolerances.ErrorRate)},
+		{"fail-fast", fmt.Sprint(c.FailFast)},
+		{"max-failures", fmt.Sprint(c.MaxFailures)},
+		{"max-failure-rate", fmt.Sprint(c.MaxFailureRate)},
+		{"min-avg", fmt.Sprint(c.Thresholds.MinAvgBps)},
+		{"min-p10", fmt.Sprint(c.Thresholds.MinP10Bps)},
+		{"max-error-rate", c.maxErrorRateString()},
+		{"max-ttfb-p95", c.Thresholds.MaxTTFBP95.String()},
+		{"history-dir", c.HistoryDir},
+		{"label", c.Label},
+		{"config", c.ConfigPath},
+		{"profile", c.Profile},
+	}
+}
+
+func (c *Config) maxErrorRateString() string {
+	return (&ratioFlag{v: c.Thresholds.MaxErrorRate}).String()
+}
+
+func (c *Config) outString() string {
+	if c.Stdout {
+		return "-"
+	}
+	return c.OutDir
+}
+
+// headerString renders headers deterministically, masking values so
+// credentials do not end up in CI logs.
+func (c *Config) headerString() string {
+	names := make([]string, 0, len(c.Headers))
+	for k := range c.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		for range c.Headers[k] {
+			parts = append(parts, k+": ***")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
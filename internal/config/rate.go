@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// ParseBitRate parses throughputs such as "500Mbit", "1.5Gbit/s", "800k"
+// or "2000000" into bit/s. Units are decimal (K = 1000) bits, matching the
+// rates printed by bandfetch.
+func ParseBitRate(s string) (float64, error) {
+	in := strings.TrimSpace(s)
+	lower := strings.ToLower(in)
+	lower = strings.TrimSuffix(lower, "/s")
+	lower = strings.TrimSuffix(lower, "bps")
+	lower = strings.TrimSuffix(lower, "bit")
+
+	mult := 1.0
+	if lower != "" {
+		switch lower[len(lower)-1] {
+		case 'k':
+			mult = 1e3
+		case 'm':
+			mult = 1e6
+		case 'g':
+			mult = 1e9
+		}
+		if mult > 1 {
+			lower = lower[:len(lower)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(lower), 64)
+	if err != nil || n < 0 || !finite(n*mult) {
+		return 0, fmt.Errorf("invalid bit rate %q", s)
+	}
+	return n * mult, nil
+}
+
+// finite reports whether f is neither NaN nor infinite.
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// bitRateFlag is a flag.Value holding a ParseBitRate result; 0 means unset.
+type bitRateFlag float64
+
+func (b *bitRateFlag) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	return strings.ReplaceAll(metrics.HumanBitsPerSecond(float64(*b)), " ", "")
+}
+
+func (b *bitRateFlag) Set(s string) error {
+	n, err := ParseBitRate(s)
+	if err != nil {
+		return err
+	}
+	*b = bitRateFlag(n)
+	return nil
+}
+
+// ParseRatio parses a fraction written as "1%" or "0.01" into 0.01.
+func ParseRatio(s string) (float64, error) {
+	in := strings.TrimSpace(s)
+	div := 1.0
+	if strings.HasSuffix(in, "%") {
+		in, div = strings.TrimSpace(strings.TrimSuffix(in, "%")), 100
+	}
+	n, err := strconv.ParseFloat(in, 64)
+	if err != nil || n < 0 || !finite(n) {
+		return 0, fmt.Errorf("invalid ratio %q", s)
+	}
+	return n / div, nil
+}
+
+// ratioFlag is a flag.Value holding an optional ParseRatio result, so that
+// an explicit 0 differs from unset.
+type ratioFlag struct {
+	v *float64
+}
+
+func (r *ratioFlag) String() string {
+	if r.v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*r.v*100, 'f', -1, 64) + "%"
+}
+
+func (r *ratioFlag) Set(s string) error {
+	n, err := ParseRatio(s)
+	if err != nil {
+		return err
+	}
+	r.v = &n
+	return nil
+}
+
+// get returns the ratio, or 0 if unset.
+func (r *ratioFlag) get() float64 {
+	if r.v == nil {
+		return 0
+	}
+	return *r.v
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseByteSize parses sizes such as "65536", "64K", "4MiB" or "1G". Unit
+// suffixes are binary (K = 1024) with or without the "iB"/"B" ending.
+func ParseByteSize(s string) (int64, error) {
+	in := strings.TrimSpace(s)
+	upper := strings.ToUpper(in)
+	upper = strings.TrimSuffix(upper, "IB")
+	upper = strings.TrimSuffix(upper, "B")
+
+	mult := int64(1)
+	if upper != "" {
+		switch upper[len(upper)-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			upper = upper[:len(upper)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(upper), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * mult, nil
+}
+
+// byteSizeFlag is a flag.Value holding a ParseByteSize result.
+type byteSizeFlag int64
+
+func (b *byteSizeFlag) String() string { return strconv.FormatInt(int64(*b), 10) }
+
+func (b *byteSizeFlag) Set(s string) error {
+	n, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = byteSizeFlag(n)
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/cx009/netperf/internal/downloader"
+	"github.com/cx009/netperf/internal/sweep"
+)
+
+// SweepConfig holds the settings of the sweep subcommand: the common
+// settings plus the grid of steps to measure.
+type SweepConfig struct {
+	*Config
+	SweepWorkers []int
+	Protocols    []downloader.Protocol
+	StepDuration time.Duration
+	// CSVPath, if set, receives the results as CSV.
+	CSVPath string
+}
+
+// ParseSweep parses the arguments of "bandfetch sweep". It accepts every
+// regular flag (through the same env and config file layers) except the
+// ones that save downloads, since a sweep only measures throughput.
+func ParseSweep(fs *flag.FlagSet, args []string) (*SweepConfig, error) {
+	workers := fs.String("sweep-workers", "1..64", "worker counts to measure, e.g. 1,2,4,8 or 1..64 (doubling)")
+	protocols := fs.String("protocols", "http2", "comma-separated protocols to measure: http1, http2")
+	step := fs.Duration("step", 10*time.Second, "how long each sweep step runs")
+	csvPath := fs.String("csv", "", "also write the results as CSV to this file")
+
+	cfg, err := Parse(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Save || cfg.Stdout || cfg.Archive != "" {
+		return nil, errors.New("sweep discards downloads; -save, -out and -archive cannot be used")
+	}
+
+	sc := &SweepConfig{Config: cfg, StepDuration: *step, CSVPath: *csvPath}
+	if sc.SweepWorkers, err = sweep.ParseWorkers(*workers); err != nil {
+		return nil, cfg.invalid("sweep-workers", err.Error())
+	}
+	for _, p := range strings.Split(*protocols, ",") {
+		proto, err := downloader.ParseProtocol(p)
+		if err != nil {
+			return nil, cfg.invalid("protocols", err.Error())
+		}
+		sc.Protocols = append(sc.Protocols, proto)
+	}
+	if sc.StepDuration <= 0 {
+		return nil, cfg.invalid("step", "-step must be greater than 0")
+	}
+	return sc, nil
+}
@@ -0,0 +1,222 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveFormat identifies the container written by an Archive.
+type ArchiveFormat string
+
+const (
+	ArchiveTar   ArchiveFormat = "tar"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// ArchiveFormatFor infers the archive format from a file name.
+func ArchiveFormatFor(name string) (ArchiveFormat, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	}
+	return "", fmt.Errorf("archive %q: want a .tar, .tar.gz, .tgz or .zip name", name)
+}
+
+var errArchiveClosed = errors.New("archive already closed")
+
+// Archive streams completed downloads into a single tar, tar.gz or zip
+// file. Each download is spooled to a temporary file next to the archive
+// and appended as one entry when it commits, so concurrent workers never
+// interleave and failed downloads leave no trace. Close must be called,
+// including after an interrupted run, to write the archive trailer.
+type Archive struct {
+	path   string
+	format ArchiveFormat
+
+	mu     sync.Mutex
+	f      *os.File
+	gz     *gzip.Writer
+	tw     *tar.Writer
+	zw     *zip.Writer
+	names  map[string]int
+	closed bool
+}
+
+// OpenArchive creates the archive at path, choosing the format from its
+// extension.
+func OpenArchive(path string) (*Archive, error) {
+	format, err := ArchiveFormatFor(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{path: path, format: format, f: f, names: map[string]int{}}
+	switch format {
+	case ArchiveTar:
+		a.tw = tar.NewWriter(f)
+	case ArchiveTarGz:
+		a.gz = gzip.NewWriter(f)
+		a.tw = tar.NewWriter(a.gz)
+	case ArchiveZip:
+		a.zw = zip.NewWriter(f)
+	}
+	return a, nil
+}
+
+// Sinks returns a factory whose sinks append to the archive under the
+// resolved output name.
+func (a *Archive) Sinks() SinkFactory {
+	return SinkFactoryFunc(func(req SinkRequest) (Sink, error) {
+		name := req.Name
+		if name == "" {
+			name = FileNameFromURL(req.URL)
+		}
+		spool, err := os.CreateTemp(filepath.Dir(a.path), ".bandfetch-spool-*")
+		if err != nil {
+			return nil, err
+		}
+		return &archiveSink{archive: a, spool: spool, name: filepath.ToSlash(name)}, nil
+	})
+}
+
+// Close finalizes the archive. It waits for an in-flight append to finish;
+// later commits fail.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	var errs []error
+	if a.tw != nil {
+		errs = append(errs, a.tw.Close())
+	}
+	if a.gz != nil {
+		errs = append(errs, a.gz.Close())
+	}
+	if a.zw != nil {
+		errs = append(errs, a.zw.Close())
+	}
+	errs = append(errs, a.f.Close())
+	return errors.Join(errs...)
+}
+
+// add copies a spooled body into the archive as name.
+func (a *Archive) add(name string, body *os.File) (string, error) {
+	info, err := body.Stat()
+	if err != nil {
+		return "", err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return "", errArchiveClosed
+	}
+	name = a.uniqueName(name)
+	modTime := time.Now()
+
+	if a.zw != nil {
+		w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(w, body)
+		return name, err
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		ModTime:  modTime,
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return "", err
+	}
+	_, err = io.Copy(a.tw, body)
+	return name, err
+}
+
+// uniqueName suffixes repeated entry names ("x.bin", "x-1.bin", ...).
+// Callers hold a.mu.
+func (a *Archive) uniqueName(name string) string {
+	n := a.names[name]
+	a.names[name] = n + 1
+	if n == 0 {
+		return name
+	}
+	ext := path.Ext(name)
+	candidate := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+	return a.uniqueName(candidate)
+}
+
+// archiveSink spools one download until it commits.
+type archiveSink struct {
+	archive *Archive
+	spool   *os.File
+	name    string
+	entry   string
+}
+
+func (s *archiveSink) Write(p []byte) (int, error) { return s.spool.Write(p) }
+
+func (s *archiveSink) Commit() error {
+	defer s.discardSpool()
+	entry, err := s.archive.add(s.name, s.spool)
+	if err != nil {
+		return err
+	}
+	s.entry = entry
+	return nil
+}
+
+func (s *archiveSink) Abort() error {
+	return s.discardSpool()
+}
+
+// Destination reports "<archive>:<entry>" once committed.
+func (s *archiveSink) Destination() string {
+	entry := s.entry
+	if entry == "" {
+		entry = s.name
+	}
+	return s.archive.path + ":" + entry
+}
+
+func (s *archiveSink) discardSpool() error {
+	s.spool.Close()
+	if err := os.Remove(s.spool.Name()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func archiveServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("body of " + r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runIntoArchive(t *testing.T, path string, srv *httptest.Server) {
+	t.Helper()
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{Sink: archive.Sinks()})
+	list := []string{srv.URL + "/a.bin", srv.URL + "/b.bin", srv.URL + "/x/a.bin", srv.URL + "/fail.bin"}
+	if err := NewManager(dl, 4).Run(context.Background(), list); err == nil {
+		t.Fatalf("expected the failing URL to be reported")
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	spools, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".bandfetch-spool-*"))
+	if len(spools) != 0 {
+		t.Fatalf("expected spool files to be removed, got %v", spools)
+	}
+}
+
+func TestArchiveTarGz(t *testing.T) {
+	srv := archiveServer(t)
+	path := filepath.Join(t.TempDir(), "out.tar.gz")
+	runIntoArchive(t, path, srv)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(data)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries without the failure, got %v", got)
+	}
+	if got["b.bin"] != "body of /b.bin" {
+		t.Fatalf("unexpected b.bin contents: %q", got["b.bin"])
+	}
+	if _, ok := got["a-1.bin"]; !ok {
+		t.Fatalf("expected duplicate name to be suffixed, got %v", got)
+	}
+}
+
+func TestArchiveZip(t *testing.T) {
+	srv := archiveServer(t)
+	path := filepath.Join(t.TempDir(), "out.zip")
+	runIntoArchive(t, path, srv)
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a-1.bin,a.bin,b.bin" {
+		t.Fatalf("unexpected entries: %v", names)
+	}
+}
+
+func TestArchiveRejectsCommitAfterClose(t *testing.T) {
+	archive, err := OpenArchive(filepath.Join(t.TempDir(), "out.tar"))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	sink, err := archive.Sinks().Open(SinkRequest{Name: "late.bin"})
+	if err != nil {
+		t.Fatalf("open sink: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := sink.Commit(); err == nil {
+		t.Fatalf("expected commit after close to fail")
+	}
+}
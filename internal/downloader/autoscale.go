@@ -0,0 +1,163 @@
+package downloader
+
+import (
+	"context"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// Autoscale defaults.
+const (
+	DefaultAutoscaleMax       = 256
+	DefaultAutoscaleInterval  = 3 * time.Second
+	DefaultAutoscaleThreshold = 0.05
+	// MinAutoscaleInterval keeps each of a level's samples at least a
+	// millisecond apart.
+	MinAutoscaleInterval = samplesPerLevel * time.Millisecond
+	// samplesPerLevel throughput samples feed each level's EWMA.
+	samplesPerLevel = 4
+)
+
+// AutoscaleOptions configures adaptive concurrency. The pool starts at Min
+// workers and doubles while the smoothed throughput of each level beats the
+// best level so far by Threshold; it settles on the best level once gains
+// plateau or the error rate rises by more than ErrorThreshold.
+type AutoscaleOptions struct {
+	Min int
+	Max int
+	// Interval is how long each concurrency level is measured.
+	Interval time.Duration
+	// Threshold is the relative EWMA gain (0.05 = 5%) required to keep growing.
+	Threshold float64
+	// ErrorThreshold is the rise in failed/finished ratio that triggers a back-off.
+	ErrorThreshold float64
+}
+
+func (o AutoscaleOptions) withDefaults() AutoscaleOptions {
+	if o.Min < 1 {
+		o.Min = 1
+	}
+	if o.Max < 1 {
+		o.Max = DefaultAutoscaleMax
+	}
+	if o.Max < o.Min {
+		o.Max = o.Min
+	}
+	if o.Interval <= 0 {
+		o.Interval = DefaultAutoscaleInterval
+	}
+	if o.Interval < MinAutoscaleInterval {
+		o.Interval = MinAutoscaleInterval
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultAutoscaleThreshold
+	}
+	if o.ErrorThreshold <= 0 {
+		o.ErrorThreshold = DefaultAutoscaleThreshold
+	}
+	return o
+}
+
+// LevelSample is the throughput measured at one concurrency level.
+type LevelSample struct {
+	Workers   int
+	Bps       float64 // EWMA of bit/s over the level's interval
+	ErrorRate float64 // failed / finished downloads during the interval
+}
+
+// AutoscaleReport describes how the pool scaled during a run.
+type AutoscaleReport struct {
+	Levels []LevelSample
+	// Workers is the level the pool settled on: the saturation point when
+	// Saturated is set, otherwise the best level seen before the run ended
+	// or the ceiling was reached.
+	Workers   int
+	Bps       float64
+	Saturated bool
+}
+
+// autoscale grows p, already running opts.Min workers, until a saturation
+// point is found or the run finishes (stop is closed), and returns what it
+// observed.
+func (m *Manager) autoscale(ctx context.Context, p *pool, agg *metrics.Aggregator, stop <-chan struct{}) AutoscaleReport {
+	opts := m.scale.withDefaults()
+	var report AutoscaleReport
+
+	level := opts.Min
+	var best LevelSample
+	for {
+		sample, ok := measureLevel(ctx, p, agg, level, opts.Interval, stop)
+		if !ok {
+			break
+		}
+		report.Levels = append(report.Levels, sample)
+		m.printf("[AUTO] workers=%d ewma=%s errors=%.1f%%\n",
+			sample.Workers, metrics.HumanBitsPerSecond(sample.Bps), sample.ErrorRate*100)
+
+		if best.Workers > 0 && sample.ErrorRate > best.ErrorRate+opts.ErrorThreshold {
+			report.Saturated = true
+			break
+		}
+		if best.Workers > 0 && sample.Bps < best.Bps*(1+opts.Threshold) {
+			report.Saturated = true
+			break
+		}
+		best = sample
+		if level >= opts.Max {
+			break
+		}
+		level = min(level*2, opts.Max)
+		p.resize(level)
+	}
+
+	if best.Workers == 0 {
+		best.Workers = level
+	}
+	report.Workers = best.Workers
+	report.Bps = best.Bps
+	if report.Saturated {
+		p.resize(best.Workers)
+		m.printf("[AUTO] saturated at %d workers (%s)\n",
+			best.Workers, metrics.HumanBitsPerSecond(best.Bps))
+	}
+	agg.SetSaturation(metrics.Saturation{
+		Workers:   report.Workers,
+		Bps:       report.Bps,
+		Saturated: report.Saturated,
+	})
+	return report
+}
+
+// measureLevel samples throughput for one interval at the given level. It
+// reports false if the run ends first.
+func measureLevel(ctx context.Context, p *pool, agg *metrics.Aggregator, workers int, interval time.Duration, stop <-chan struct{}) (LevelSample, bool) {
+	tick := max(interval/samplesPerLevel, time.Millisecond)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	ewma := metrics.NewEWMA(0.5)
+	startDone, startFailed := p.done.Load(), p.failed.Load()
+	lastBytes, last := agg.TotalBytes(), time.Now()
+	var bps float64
+	for i := 0; i < samplesPerLevel; i++ {
+		select {
+		case <-ctx.Done():
+			return LevelSample{}, false
+		case <-stop:
+			return LevelSample{}, false
+		case now := <-ticker.C:
+			bytes := agg.TotalBytes()
+			if secs := now.Sub(last).Seconds(); secs > 0 {
+				bps = ewma.Update(float64(bytes-lastBytes) * 8 / secs)
+			}
+			lastBytes, last = bytes, now
+		}
+	}
+
+	sample := LevelSample{Workers: workers, Bps: bps}
+	if done := p.done.Load() - startDone; done > 0 {
+		sample.ErrorRate = float64(p.failed.Load()-startFailed) / float64(done)
+	}
+	return sample, true
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"fmt"
+)
+
+// DefaultFailureRateMinFiles is how many downloads must finish before
+// MaxFailureRate is enforced, so one early failure is not a 100% rate.
+const DefaultFailureRateMinFiles = 10
+
+// FailurePolicy bounds how many downloads may fail before a run is
+// cancelled. Zero values disable each limit. Failures caused by the run's
+// own cancellation are not counted.
+type FailurePolicy struct {
+	// FailFast cancels the run on the first download that fails after
+	// all retries and mirrors.
+	FailFast bool
+	// MaxFailures cancels the run once more than this many downloads fail.
+	MaxFailures int
+	// MaxFailureRate cancels the run once the share of failed downloads
+	// exceeds it (0.2 = 20%), after at least MinFiles have finished.
+	MaxFailureRate float64
+	MinFiles       int
+}
+
+func (fp FailurePolicy) withDefaults() FailurePolicy {
+	if fp.MinFiles <= 0 {
+		fp.MinFiles = DefaultFailureRateMinFiles
+	}
+	return fp
+}
+
+func (fp FailurePolicy) active() bool {
+	return fp.FailFast || fp.MaxFailures > 0 || fp.MaxFailureRate > 0
+}
+
+// exceeded returns why failed out of done downloads breaks the policy, or
+// "" if it does not.
+func (fp FailurePolicy) exceeded(failed, done int64) string {
+	switch {
+	case fp.FailFast && failed > 0:
+		return "fail-fast on first failure"
+	case fp.MaxFailures > 0 && failed > int64(fp.MaxFailures):
+		return fmt.Sprintf("over %d failed downloads", fp.MaxFailures)
+	case fp.MaxFailureRate > 0 && done >= int64(fp.MinFiles) && float64(failed)/float64(done) > fp.MaxFailureRate:
+		return fmt.Sprintf("failure rate %.1f%% > %.1f%%", float64(failed)/float64(done)*100, fp.MaxFailureRate*100)
+	}
+	return ""
+}
+
+// AbortError is returned by Manager runs cancelled by their FailurePolicy.
+type AbortError struct {
+	Reason string
+	// Failed is the number of failed downloads when the run was cancelled.
+	Failed int64
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("run aborted (%s) after %d failed downloads", e.Reason, e.Failed)
+}
@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// readError and writeError mark which side of copyBody failed, so that
+// Classify can tell body read timeouts and disk errors apart. Both keep
+// the wrapped error's message.
+type readError struct{ err error }
+
+func (e *readError) Error() string { return e.err.Error() }
+func (e *readError) Unwrap() error { return e.err }
+
+type writeError struct{ err error }
+
+func (e *writeError) Error() string { return e.err.Error() }
+func (e *writeError) Unwrap() error { return e.err }
+
+// Classify returns the category of an error from Download or
+// DownloadEntry, or "" for nil.
+func Classify(err error) metrics.ErrorClass {
+	if err == nil {
+		return ""
+	}
+	var stall *StallError
+	if errors.As(err, &stall) {
+		return metrics.ClassStall
+	}
+	if errors.Is(err, context.Canceled) {
+		return metrics.ClassCancelled
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		if se.Code >= 500 {
+			return metrics.ClassHTTP5xx
+		}
+		return metrics.ClassHTTP4xx
+	}
+	var ve *VerifyError
+	if errors.As(err, &ve) {
+		return metrics.ClassVerify
+	}
+
+	var we *writeError
+	var pe *fs.PathError
+	var link *os.LinkError
+	if errors.As(err, &we) || errors.As(err, &pe) || errors.As(err, &link) || errors.Is(err, syscall.ENOSPC) {
+		return metrics.ClassDisk
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return metrics.ClassDNS
+	}
+	if isTLSError(err) {
+		return metrics.ClassTLS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return metrics.ClassConnectRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return metrics.ClassReset
+	}
+	var le *LengthError
+	if errors.As(err, &le) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return metrics.ClassShortRead
+	}
+
+	var re *readError
+	inBody := errors.As(err, &re)
+	var ne net.Error
+	if (errors.As(err, &ne) && ne.Timeout()) || errors.Is(err, context.DeadlineExceeded) {
+		var op *net.OpError
+		switch {
+		case inBody:
+			return metrics.ClassBodyTimeout
+		case errors.As(err, &op) && op.Op == "dial":
+			return metrics.ClassConnectTimeout
+		}
+		return metrics.ClassTimeout
+	}
+	return metrics.ClassOther
+}
+
+func isTLSError(err error) bool {
+	var (
+		header   tls.RecordHeaderError
+		alert    tls.AlertError
+		verify   *tls.CertificateVerificationError
+		unknown  x509.UnknownAuthorityError
+		hostname x509.HostnameError
+		invalid  x509.CertificateInvalidError
+	)
+	return errors.As(err, &header) || errors.As(err, &alert) || errors.As(err, &verify) ||
+		errors.As(err, &unknown) || errors.As(err, &hostname) || errors.As(err, &invalid)
+}
@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+func TestClassifySyntheticErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want metrics.ErrorClass
+	}{
+		{nil, ""},
+		{&StatusError{Code: 404}, metrics.ClassHTTP4xx},
+		{fmt.Errorf("mirror: %w", &StatusError{Code: 503}), metrics.ClassHTTP5xx},
+		{&VerifyError{What: "size"}, metrics.ClassVerify},
+		{&net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}, metrics.ClassDNS},
+		{&writeError{&os.PathError{Op: "write", Path: "f", Err: errors.New("no space left on device")}}, metrics.ClassDisk},
+		{&readError{io.ErrUnexpectedEOF}, metrics.ClassShortRead},
+		{&readError{context.DeadlineExceeded}, metrics.ClassBodyTimeout},
+		{&StallError{Idle: time.Second}, metrics.ClassStall},
+		{&net.OpError{Op: "dial", Err: context.DeadlineExceeded}, metrics.ClassConnectTimeout},
+		{fmt.Errorf("get: %w", context.Canceled), metrics.ClassCancelled},
+		{errors.New("something else"), metrics.ClassOther},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyNetworkErrors(t *testing.T) {
+	// A listener closed before use gives a port that refuses connections.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + ln.Addr().String()
+	ln.Close()
+
+	short := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("partial"))
+	}))
+	t.Cleanup(short.Close)
+
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first bytes"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(stalled.Close)
+
+	tests := []struct {
+		url  string
+		want metrics.ErrorClass
+	}{
+		{refused, metrics.ClassConnectRefused},
+		{short.URL, metrics.ClassShortRead},
+		{stalled.URL, metrics.ClassStall},
+	}
+	for _, tt := range tests {
+		dl := New(NewHTTPClient(time.Second), nil, Options{StallTimeout: 200 * time.Millisecond})
+		_, err := dl.Download(context.Background(), tt.url)
+		if got := Classify(err); got != tt.want {
+			t.Errorf("%s: Classify(%v) = %q, want %q", tt.url, err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// newBufferPool returns a pool of size-byte copy buffers. Pooling keeps
+// the per-attempt allocation off the heap so GC pauses do not distort
+// bandwidth measurements with many workers and small objects.
+func newBufferPool(size int) *sync.Pool {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &sync.Pool{
+		New: func() any {
+			buf := make([]byte, size)
+			return &buf
+		},
+	}
+}
+
+// copyBody streams src into sink, metering bytes and blocked time.
+//
+// Sinks implementing io.ReaderFrom (discard and file sinks) pull from the
+// metered reader themselves when nothing needs to see the bytes in
+// between, skipping the per-chunk timing and verifier wrappers. Either way
+// the body is read through the pooled buffer, so the read size, and with
+// it the measured throughput, follows -buffer-size.
+func (d *Downloader) copyBody(sink Sink, src io.Reader, v *verifier) (int64, error) {
+	reader := &meteredReader{src: src, agg: d.agg}
+	bufp := d.buffers.Get().(*[]byte)
+	defer d.buffers.Put(bufp)
+
+	if rf, ok := sink.(io.ReaderFrom); ok && !v.active() {
+		start := time.Now()
+		n, err := rf.ReadFrom(pooledReader{reader, *bufp})
+		if d.agg != nil {
+			d.agg.AddWriteTime(time.Since(start) - reader.blocked)
+		}
+		return n, err
+	}
+
+	writer := v.wrap(&timedWriter{dst: sink, agg: d.agg})
+	return io.CopyBuffer(writer, reader, *bufp)
+}
+
+// pooledReader hands a sink's ReadFrom the Downloader's copy buffer: its
+// WriteTo copies through buf, so the fast path keeps -buffer-size reads.
+type pooledReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (p pooledReader) Read(b []byte) (int, error) { return p.r.Read(b) }
+
+func (p pooledReader) WriteTo(w io.Writer) (int64, error) {
+	return io.CopyBuffer(writerOnly{w}, p.r, p.buf)
+}
+
+// readFrom implements a sink's ReadFrom by writing r into w, through the
+// caller's buffer when r is a pooledReader.
+func readFrom(w io.Writer, r io.Reader) (int64, error) {
+	if wt, ok := r.(io.WriterTo); ok {
+		return wt.WriteTo(w)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// writerOnly hides any ReadFrom method of the wrapped writer so that
+// io.CopyBuffer uses the buffer it is given.
+type writerOnly struct{ io.Writer }
+
+// meteredReader counts bytes read from the network into the aggregator
+// and accumulates the time spent blocked in Read.
+type meteredReader struct {
+	src     io.Reader
+	agg     *metrics.Aggregator
+	blocked time.Duration
+}
+
+func (mr *meteredReader) Read(p []byte) (int, error) {
+	if mr.agg == nil {
+		n, err := mr.src.Read(p)
+		return n, wrapRead(err)
+	}
+	start := time.Now()
+	n, err := mr.src.Read(p)
+	elapsed := time.Since(start)
+	mr.blocked += elapsed
+	mr.agg.AddReadTime(elapsed)
+	mr.agg.AddBytes(n)
+	return n, wrapRead(err)
+}
+
+func wrapRead(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return &readError{err}
+}
+
+// timedWriter accumulates time spent blocked writing into the sink.
+type timedWriter struct {
+	dst io.Writer
+	agg *metrics.Aggregator
+}
+
+func (tw *timedWriter) Write(p []byte) (int, error) {
+	var start time.Time
+	if tw.agg != nil {
+		start = time.Now()
+	}
+	n, err := tw.dst.Write(p)
+	if tw.agg != nil {
+		tw.agg.AddWriteTime(time.Since(start))
+	}
+	if err != nil {
+		err = &writeError{err}
+	}
+	return n, err
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cx009/netperf/internal/metrics"
+	"github.com/cx009/netperf/internal/urls"
 )
 
 // Options holds parameters for Downloader behaviour.
@@ -18,49 +22,246 @@ type Options struct {
 	Save    bool
 	OutDir  string
 	Retries int
+	// ProbeMirrors reorders an entry's mirrors by a quick HEAD probe before
+	// downloading so the fastest responder is tried first.
+	ProbeMirrors bool
+	// Headers are added to every request.
+	Headers http.Header
+	// OnConflict decides what happens when a saved file already exists.
+	OnConflict ConflictPolicy
+	// NameTemplate lays out saved files, e.g. "{host}/{path}"; empty
+	// means the bare file name.
+	NameTemplate string
+	// PreservePaths mirrors the URL host and path under OutDir.
+	PreservePaths bool
+	// Incremental records ETag/Last-Modified/size in a manifest in OutDir
+	// and revalidates with conditional requests on later runs.
+	Incremental bool
+	// Sink, when set, receives every download instead of the built-in
+	// file (Save) or discard sinks.
+	Sink SinkFactory
+	// Preallocate and Fsync tune the built-in file sink; see FileSinkOptions.
+	Preallocate bool
+	Fsync       bool
+	// BufferSize is the copy buffer size in bytes (DefaultBufferSize if 0).
+	BufferSize int
+	// StallTimeout aborts an attempt whose body delivers no bytes for this
+	// long (DefaultStallTimeout if 0, never if NoStallTimeout), so a hung
+	// server cannot block a worker forever. LowSpeedLimit aborts one slower than
+	// this many bytes/s for LowSpeedTime (DefaultLowSpeedTime if 0); 0
+	// disables it.
+	StallTimeout  time.Duration
+	LowSpeedLimit int64
+	LowSpeedTime  time.Duration
 }
 
+// DefaultBufferSize is the copy buffer used when Options.BufferSize is 0.
+const DefaultBufferSize = 1 << 20
+
 // Downloader performs download operations with retry policies.
 type Downloader struct {
-	client *http.Client
-	agg    *metrics.Aggregator
-	opts   Options
+	client  *http.Client
+	agg     *metrics.Aggregator
+	opts    Options
+	sinks   SinkFactory
+	buffers *sync.Pool
+
+	manifestOnce sync.Once
+	manifest     *manifest
+	manifestErr  error
 }
 
 // Result describes the outcome of a download attempt.
 type Result struct {
 	Destination string
 	Discarded   bool
+	// Mirror is the URL that actually served the object.
+	Mirror string
+	// Skipped is set when the destination existed under ConflictSkip.
+	Skipped bool
+	// Unchanged is set when an incremental revalidation returned 304.
+	Unchanged bool
+	// Bytes is the body size received by the successful attempt.
+	Bytes int64
+	// Timing breaks down the latency of the successful attempt.
+	Timing Timing
+}
+
+// RequestError reports a URL that cannot be requested at all, such as a
+// malformed URL or an unsupported scheme. It is neither retried nor failed
+// over, since every attempt would fail the same way.
+type RequestError struct {
+	URL string
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("invalid URL %q: %v", e.URL, e.Err)
+}
+
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// NameError reports a download whose output name cannot be derived, for
+// example because the name template would escape the output directory.
+// Like RequestError it is not retried.
+type NameError struct {
+	URL string
+	Err error
+}
+
+func (e *NameError) Error() string {
+	return fmt.Sprintf("output name for %q: %v", e.URL, e.Err)
+}
+
+func (e *NameError) Unwrap() error { return e.Err }
+
+// StatusError reports a non-2xx HTTP response.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.Code)
 }
 
 // New initializes a Downloader instance.
 func New(client *http.Client, agg *metrics.Aggregator, opts Options) *Downloader {
+	sinks := opts.Sink
+	if sinks == nil {
+		if opts.Save {
+			sinks = FileSinks(opts.OutDir, FileSinkOptions{
+				OnConflict:  opts.OnConflict,
+				Preallocate: opts.Preallocate,
+				Fsync:       opts.Fsync,
+			})
+		} else {
+			sinks = DiscardSinks()
+		}
+	}
 	return &Downloader{
-		client: client,
-		agg:    agg,
-		opts:   opts,
+		client:  client,
+		agg:     agg,
+		opts:    opts,
+		sinks:   sinks,
+		buffers: newBufferPool(opts.BufferSize),
 	}
 }
 
+// Close releases the incremental manifest, if one was opened.
+func (d *Downloader) Close() error {
+	return d.manifest.close()
+}
+
 // Download retrieves a single URL using retry semantics.
 func (d *Downloader) Download(ctx context.Context, rawURL string) (Result, error) {
+	return d.DownloadEntry(ctx, urls.Entry{Mirrors: []string{rawURL}})
+}
+
+// DownloadEntry retrieves an object from the first mirror that serves it.
+// Connection errors and 5xx responses fail over to the next mirror within
+// the same attempt, and mirrors with an invalid URL are skipped; once every
+// mirror has been tried the attempt counts against the retry budget.
+// Failures that would recur, such as 4xx responses (other than 408 and
+// 429) and checksum mismatches, are not retried.
+func (d *Downloader) DownloadEntry(ctx context.Context, entry urls.Entry) (Result, error) {
+	start := time.Now()
+	var st transferStats
+	res, err := d.downloadEntry(ctx, entry, &st)
+	if f, ok := d.sinks.(EntryFinisher); ok {
+		f.FinishEntry(entry, err)
+	}
+	if d.agg != nil && (err == nil || ctx.Err() == nil) {
+		outcome := metrics.FileDownloaded
+		switch {
+		case err != nil:
+			outcome = metrics.FileFailed
+		case res.Unchanged:
+			outcome = metrics.FileUnchanged
+		case res.Skipped:
+			outcome = metrics.FileSkipped
+		}
+		d.agg.RecordFile(outcome)
+		if outcome == metrics.FileDownloaded {
+			d.agg.AddGoodput(res.Bytes)
+		}
+		d.agg.RecordTransfer(newTransfer(entry, res, err, outcome, start, st))
+	}
+	return res, err
+}
+
+// newTransfer describes a finished entry for the aggregator's reports.
+func newTransfer(entry urls.Entry, res Result, err error, outcome metrics.FileOutcome, start time.Time, st transferStats) metrics.Transfer {
+	t := metrics.Transfer{
+		URL:      entry.URL(),
+		Host:     hostOf(entry.URL()),
+		Mirror:   res.Mirror,
+		Outcome:  outcome,
+		Start:    start,
+		Duration: time.Since(start),
+		Attempts: st.attempts,
+		Bytes:    st.bytes,
+		DNS:      st.timing.DNS,
+		Connect:  st.timing.Connect,
+		TLS:      st.timing.TLS,
+		TTFB:     st.timing.TTFB,
+	}
+	if err != nil {
+		t.Err = err.Error()
+		t.Class = Classify(err)
+	}
+	return t
+}
+
+// hostOf returns the host of rawURL, or rawURL itself if it does not parse.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
+
+func (d *Downloader) downloadEntry(ctx context.Context, entry urls.Entry, st *transferStats) (Result, error) {
 	if d.client == nil {
 		return Result{}, errors.New("http client not configured")
 	}
+	if len(entry.Mirrors) == 0 {
+		return Result{}, errors.New("entry has no URLs")
+	}
+
+	if d.opts.Incremental {
+		d.manifestOnce.Do(func() {
+			d.manifest, d.manifestErr = openManifest(d.opts.OutDir)
+		})
+		if d.manifestErr != nil {
+			return Result{}, d.manifestErr
+		}
+	}
+
+	mirrors := entry.Mirrors
+	if d.opts.ProbeMirrors && len(mirrors) > 1 {
+		mirrors = d.probeMirrors(ctx, mirrors)
+	}
 
 	var lastErr error
 	baseDelay := 500 * time.Millisecond
 
 	for attempt := 0; attempt <= d.opts.Retries; attempt++ {
-		res, err := d.tryOnce(ctx, rawURL)
+		res, err := d.tryMirrors(ctx, entry, mirrors, st)
 		if err == nil {
 			return res, nil
 		}
 		lastErr = err
 
+		if !shouldRetry(err) {
+			return res, err
+		}
 		if ctx.Err() != nil {
 			return res, ctx.Err()
 		}
+		if d.sinkErr() != nil {
+			return res, err
+		}
 		if attempt == d.opts.Retries {
 			break
 		}
@@ -76,65 +277,271 @@ func (d *Downloader) Download(ctx context.Context, rawURL string) (Result, error
 	return Result{}, lastErr
 }
 
-func (d *Downloader) tryOnce(ctx context.Context, rawURL string) (Result, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+// sinkErr reports a SinkChecker failure, if the sink factory has one.
+func (d *Downloader) sinkErr() error {
+	if c, ok := d.sinks.(SinkChecker); ok {
+		return c.Err()
+	}
+	return nil
+}
+
+// tryMirrors walks the mirror list once, moving on only for failures that
+// another mirror could plausibly avoid.
+func (d *Downloader) tryMirrors(ctx context.Context, entry urls.Entry, mirrors []string, st *transferStats) (Result, error) {
+	var err error
+	for _, mirror := range mirrors {
+		res, merr := d.tryOnce(ctx, mirror, entry, st)
+		if merr == nil {
+			return res, nil
+		}
+		// An unusable mirror URL says nothing about the others; skip it,
+		// but report a real failure from another mirror in preference.
+		var re *RequestError
+		if errors.As(merr, &re) {
+			if err == nil {
+				err = merr
+			}
+			continue
+		}
+		err = merr
+		if ctx.Err() != nil || !shouldFailover(err) {
+			return res, err
+		}
+	}
+	return Result{}, err
+}
+
+// shouldRetry reports whether another pass over the mirrors could succeed
+// after err, rather than fail the same way.
+func shouldRetry(err error) bool {
+	var (
+		re *RequestError
+		ne *NameError
+		ve *VerifyError
+		se *StatusError
+	)
+	switch {
+	case errors.Is(err, ErrDestinationExists), errors.As(err, &re), errors.As(err, &ne), errors.As(err, &ve):
+		return false
+	case errors.As(err, &se):
+		return se.Code >= 500 || se.Code == http.StatusRequestTimeout || se.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// shouldFailover reports whether err indicates a mirror-side problem.
+func shouldFailover(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500
+	}
+	var ve *VerifyError
+	if errors.As(err, &ve) {
+		return true
+	}
+	var le *LengthError
+	if errors.As(err, &le) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var stall *StallError
+	if errors.As(err, &stall) {
+		return true
+	}
+	var re *RequestError
+	if errors.As(err, &re) {
+		return false
+	}
+	// *url.Error satisfies net.Error itself, so only its cause is checked:
+	// dial, DNS, timeout, TLS and dropped-connection failures qualify.
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || isTLSError(err) || errors.Is(err, io.EOF)
+}
+
+func (d *Downloader) tryOnce(ctx context.Context, rawURL string, entry urls.Entry, st *transferStats) (Result, error) {
+	if err := d.sinkErr(); err != nil {
+		return Result{}, err
+	}
+	st.attempts++
+	st.bytes = 0
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	ctx, trace := withTrace(ctx)
+	defer func() { st.timing = trace.result() }()
+
+	req, err := d.newRequest(ctx, http.MethodGet, rawURL)
 	if err != nil {
 		return Result{}, err
 	}
 
+	var prev manifestRecord
+	var havePrev bool
+	if d.manifest != nil {
+		prev, havePrev = d.manifest.lookup(entry.URL())
+		if havePrev {
+			if prev.ETag != "" {
+				req.Header.Set("If-None-Match", prev.ETag)
+			}
+			if prev.LastModified != "" {
+				req.Header.Set("If-Modified-Since", prev.LastModified)
+			}
+		}
+	}
+
 	resp, err := d.client.Do(req)
 	if err != nil {
 		return Result{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && havePrev {
+		return Result{Destination: prev.Path, Unchanged: true, Mirror: rawURL}, nil
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return Result{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		return Result{}, &StatusError{Code: resp.StatusCode}
+	}
+
+	want, err := expectedLength(resp)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if entry.Size > 0 && resp.ContentLength >= 0 && resp.ContentLength != entry.Size {
+		return Result{}, &VerifyError{What: "size", Want: fmt.Sprint(entry.Size), Got: fmt.Sprint(resp.ContentLength)}
+	}
+
+	verifier, err := newVerifier(entry)
+	if err != nil {
+		return Result{}, err
 	}
 
-	var handle *sinkHandle
-	if d.opts.Save {
-		name := FileNameFromURL(rawURL)
-		handle, err = newFileSink(d.opts.OutDir, name)
-	} else {
-		handle, err = newDiscardSink()
+	sreq := SinkRequest{
+		URL:           rawURL,
+		Entry:         entry,
+		ContentLength: resp.ContentLength,
+		Header:        resp.Header,
+	}
+	if d.opts.Save || d.opts.Sink != nil {
+		sreq.Name, err = d.outputName(rawURL, entry, resp)
+		if err != nil {
+			return Result{}, &NameError{URL: rawURL, Err: err}
+		}
+	}
+	sink, err := d.sinks.Open(sreq)
+	var skip *SkipError
+	if errors.As(err, &skip) {
+		return Result{Destination: skip.Destination, Skipped: true, Mirror: rawURL}, nil
 	}
 	if err != nil {
 		return Result{}, err
 	}
 
-	writer := &counterWriter{dst: handle.writer, agg: d.agg}
-	buf := make([]byte, 1<<20)
-	if _, err := io.CopyBuffer(writer, resp.Body, buf); err != nil {
-		handle.closeWriter()
-		handle.finalizeFailure()
+	// HTTP/2 multiplexes workers onto shared connections, so they no
+	// longer stand for separate flows in the fairness figures.
+	if w := workerOf(ctx); w != nil && d.agg != nil && resp.ProtoMajor >= 2 {
+		d.agg.RecordConnUse(trace.connAddr(), w.id)
+	}
+	body := countWorkerBytes(ctx, resp.Body)
+	wd := d.watch(cancel)
+	if wd != nil {
+		body = wd.wrap(body)
+	}
+	written, err := d.copyBody(sink, body, verifier)
+	if wd != nil {
+		wd.stop()
+	}
+	st.bytes = written
+	if err != nil {
+		var stall *StallError
+		if errors.As(context.Cause(ctx), &stall) {
+			err = stall
+		}
+		sink.Abort()
 		return Result{}, err
 	}
+	if want >= 0 && written != want {
+		sink.Abort()
+		return Result{}, &LengthError{Want: want, Got: written}
+	}
 
-	if err := handle.closeWriter(); err != nil {
-		handle.finalizeFailure()
+	if err := verifier.check(); err != nil {
+		sink.Abort()
 		return Result{}, err
 	}
 
-	if err := handle.finalizeSuccess(); err != nil {
+	if err := sink.Commit(); err != nil {
 		return Result{}, err
 	}
 
-	return Result{Destination: handle.destination, Discarded: handle.discarded}, nil
+	_, discarded := sink.(discardSink)
+	dest := sink.Destination()
+	if d.manifest != nil && dest != "" {
+		rec := manifestRecord{
+			URL:          entry.URL(),
+			Path:         dest,
+			Size:         written,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if err := d.manifest.record(rec); err != nil {
+			return Result{}, err
+		}
+	}
+
+	return Result{Destination: dest, Discarded: discarded, Mirror: rawURL, Bytes: written, Timing: trace.result()}, nil
 }
 
-// counterWriter records bytes flowing through it.
-type counterWriter struct {
-	dst io.Writer
-	agg *metrics.Aggregator
+// outputName resolves the relative path a download is saved under. The
+// file name prefers Content-Disposition, then entry metadata, then the URL.
+func (d *Downloader) outputName(rawURL string, entry urls.Entry, resp *http.Response) (string, error) {
+	base := fileNameFromDisposition(resp.Header.Get("Content-Disposition"))
+	if base == "" && entry.Name != "" {
+		base = sanitize(entry.Name)
+	}
+	if base == "" {
+		base = FileNameFromURL(rawURL)
+	}
+	if d.opts.PreservePaths {
+		return preservedPath(rawURL, base)
+	}
+
+	vars := NameVars{Basename: base, Index: entry.Index}
+	if u, err := url.Parse(rawURL); err == nil {
+		vars.Host = u.Host
+		vars.Path = strings.TrimPrefix(u.Path, "/")
+		if vars.Path == "" || strings.HasSuffix(vars.Path, "/") {
+			vars.Path += base
+		}
+	}
+	return RenderName(d.opts.NameTemplate, vars)
 }
 
-func (cw *counterWriter) Write(p []byte) (int, error) {
-	n, err := cw.dst.Write(p)
-	if n > 0 && cw.agg != nil {
-		cw.agg.AddBytes(n)
+// newRequest builds a request carrying the configured extra headers.
+func (d *Downloader) newRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, &RequestError{URL: rawURL, Err: err}
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, &RequestError{URL: rawURL, Err: fmt.Errorf("unsupported scheme %q", req.URL.Scheme)}
+	}
+	if req.URL.Host == "" {
+		return nil, &RequestError{URL: rawURL, Err: errors.New("missing host")}
+	}
+	for k, vs := range d.opts.Headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
 	}
-	return n, err
+	return req, nil
 }
 
 var (
@@ -2,15 +2,19 @@ package downloader
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/cx009/netperf/internal/metrics"
+	"github.com/cx009/netperf/internal/urls"
 )
 
 func TestDownloadDiscard(t *testing.T) {
@@ -108,3 +112,385 @@ func TestDownloadHTTPError(t *testing.T) {
 		t.Fatalf("expected error on 500 response")
 	}
 }
+
+func TestDownloadEntryFailsOverOn5xx(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(bad.Close)
+	payload := []byte("mirror")
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(good.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(5*time.Second), agg, Options{Retries: 0})
+
+	res, err := dl.DownloadEntry(context.Background(), urls.Entry{Mirrors: []string{bad.URL, good.URL}})
+	if err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if res.Mirror != good.URL {
+		t.Fatalf("expected mirror %s, got %s", good.URL, res.Mirror)
+	}
+}
+
+func TestDownloadEntryNoFailoverOn4xx(t *testing.T) {
+	var goodHits int32
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(missing.Close)
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+	}))
+	t.Cleanup(good.Close)
+
+	dl := New(NewHTTPClient(5*time.Second), metrics.NewAggregator(), Options{Retries: 0})
+	if _, err := dl.DownloadEntry(context.Background(), urls.Entry{Mirrors: []string{missing.URL, good.URL}}); err == nil {
+		t.Fatalf("expected 404 to fail the entry")
+	}
+	if goodHits != 0 {
+		t.Fatalf("expected no failover on 404, got %d hits", goodHits)
+	}
+}
+
+func TestDownloadEntrySkipsInvalidMirror(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(good.Close)
+
+	dl := New(NewHTTPClient(5*time.Second), metrics.NewAggregator(), Options{Retries: 3})
+	for _, bad := range []string{"ftp://example.com/file", "http://[::1", "http:///path"} {
+		res, err := dl.DownloadEntry(context.Background(), urls.Entry{Mirrors: []string{bad, good.URL}})
+		if err != nil || res.Mirror != good.URL {
+			t.Fatalf("%s: expected the next mirror to serve, got %+v (%v)", bad, res, err)
+		}
+	}
+
+	start := time.Now()
+	_, err := dl.DownloadEntry(context.Background(), urls.Entry{Mirrors: []string{"ftp://example.com/file", "http://[::1"}})
+	var re *RequestError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected RequestError when no mirror is usable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("expected no retry backoff on invalid URLs, took %v", elapsed)
+	}
+}
+
+func TestDownloadEntryNoRetryOnPermanentErrors(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("data"))
+	}))
+	t.Cleanup(srv.Close)
+
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{Retries: 3})
+	entries := []urls.Entry{
+		{Mirrors: []string{srv.URL + "/missing"}},
+		{Mirrors: []string{srv.URL + "/data"}, Size: 99},
+	}
+	for _, e := range entries {
+		atomic.StoreInt32(&hits, 0)
+		if _, err := dl.DownloadEntry(context.Background(), e); err == nil {
+			t.Fatalf("%s: expected an error", e.Mirrors[0])
+		}
+		if n := atomic.LoadInt32(&hits); n != 1 {
+			t.Fatalf("%s: expected a single request, got %d", e.Mirrors[0], n)
+		}
+	}
+}
+
+func TestProbeMirrorsOrdersReachableFirst(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(good.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	downURL := down.URL
+	down.Close()
+
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{ProbeMirrors: true})
+	got := dl.probeMirrors(context.Background(), []string{downURL, good.URL})
+	if got[0] != good.URL || got[1] != downURL {
+		t.Fatalf("unexpected probe order: %v", got)
+	}
+}
+
+func TestDownloadEntryVerifiesHash(t *testing.T) {
+	corrupt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("corrupt"))
+	}))
+	t.Cleanup(corrupt.Close)
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	t.Cleanup(good.Close)
+
+	dir := t.TempDir()
+	dl := New(NewHTTPClient(5*time.Second), metrics.NewAggregator(), Options{Save: true, OutDir: dir})
+	entry := urls.Entry{
+		Mirrors: []string{corrupt.URL, good.URL},
+		Name:    "hello.txt",
+		Size:    5,
+		Hash: urls.Checksum{
+			Algorithm: "sha256",
+			Value:     "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+	}
+
+	res, err := dl.DownloadEntry(context.Background(), entry)
+	if err != nil {
+		t.Fatalf("expected verified download, got %v", err)
+	}
+	if res.Mirror != good.URL {
+		t.Fatalf("expected corrupt mirror to be skipped, served by %s", res.Mirror)
+	}
+	if res.Destination != filepath.Join(dir, "hello.txt") {
+		t.Fatalf("expected metalink name to be used, got %s", res.Destination)
+	}
+
+	entry.Mirrors = []string{corrupt.URL}
+	if _, err := dl.DownloadEntry(context.Background(), entry); err == nil {
+		t.Fatalf("expected verification failure")
+	}
+}
+
+func TestDownloadNameTemplateAvoidsCollisions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{Save: true, OutDir: dir, NameTemplate: "{path}"})
+	for _, p := range []string{"/x/data.bin", "/y/data.bin"} {
+		res, err := dl.Download(context.Background(), srv.URL+p)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(res.Destination)
+		if err != nil || string(data) != p {
+			t.Fatalf("expected %s to hold %q, got %q (%v)", res.Destination, p, data, err)
+		}
+	}
+}
+
+func TestDownloadIncrementalRevalidates(t *testing.T) {
+	var full int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&full, 1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("content"))
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	run := func() (Result, *metrics.Aggregator) {
+		agg := metrics.NewAggregator()
+		dl := New(NewHTTPClient(5*time.Second), agg, Options{Save: true, OutDir: dir, Incremental: true})
+		defer dl.Close()
+		res, err := dl.Download(context.Background(), srv.URL+"/file.bin")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return res, agg
+	}
+
+	first, agg := run()
+	if first.Unchanged || agg.Files(metrics.FileDownloaded) != 1 {
+		t.Fatalf("expected first run to download")
+	}
+	second, agg := run()
+	if !second.Unchanged || agg.Files(metrics.FileUnchanged) != 1 {
+		t.Fatalf("expected second run to be unchanged, got %+v", second)
+	}
+	if second.Destination != first.Destination || full != 1 {
+		t.Fatalf("expected one full transfer, got %d", full)
+	}
+
+	// A local file that no longer matches the manifest is fetched again.
+	if err := os.WriteFile(first.Destination, []byte("tampered!"), 0o644); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if third, _ := run(); third.Unchanged || full != 2 {
+		t.Fatalf("expected re-download after local change")
+	}
+}
+
+func TestManifestRelativePathsAndCompaction(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	m, err := openManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "a.bin"), []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []manifestRecord{
+		{URL: "https://h/a.bin", Path: filepath.Join(dir, "sub", "a.bin"), Size: 1, ETag: `"v1"`},
+		{URL: "https://h/a.bin", Path: filepath.Join(dir, "sub", "a.bin"), Size: 3, ETag: `"v2"`},
+		{URL: "https://h/b.bin", Path: filepath.Join(dir, "b.bin"), Size: 5},
+	} {
+		if err := m.record(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.close()
+	data, _ := os.ReadFile(filepath.Join(dir, ManifestName))
+	if !strings.Contains(string(data), `"path":"sub/a.bin"`) {
+		t.Fatalf("expected paths relative to the output directory:\n%s", data)
+	}
+
+	// The manifest still resolves after the output directory moves.
+	moved := filepath.Join(root, "moved")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	old := manifestCompactSize
+	manifestCompactSize = 1
+	t.Cleanup(func() { manifestCompactSize = old })
+	m, err = openManifest(moved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close()
+	if rec, ok := m.lookup("https://h/a.bin"); !ok || rec.ETag != `"v2"` {
+		t.Fatalf("expected the latest record to resolve, got %+v, %v", rec, ok)
+	}
+	data, _ = os.ReadFile(filepath.Join(moved, ManifestName))
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Fatalf("expected the manifest compacted to 2 lines, got %d:\n%s", n, data)
+	}
+}
+
+func TestDownloadRecordsReadAndWriteTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 256<<10))
+	}))
+	t.Cleanup(srv.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(5*time.Second), agg, Options{Save: true, OutDir: t.TempDir(), BufferSize: 4 << 10})
+	if _, err := dl.Download(context.Background(), srv.URL+"/f.bin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if agg.ReadTime() <= 0 || agg.WriteTime() <= 0 {
+		t.Fatalf("expected read and write time, got %v / %v", agg.ReadTime(), agg.WriteTime())
+	}
+}
+
+func TestCopyBodyFastPathUsesBufferSize(t *testing.T) {
+	file, err := NewFileSink(t.TempDir(), "f.bin", ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Abort()
+
+	dl := New(nil, nil, Options{BufferSize: 4 << 10})
+	for _, sink := range []Sink{NewDiscardSink(), file} {
+		if _, ok := sink.(io.ReaderFrom); !ok {
+			t.Fatalf("%T: expected an io.ReaderFrom fast path", sink)
+		}
+		var largest int
+		left := 64 << 10
+		src := readerFunc(func(p []byte) (int, error) {
+			largest = max(largest, len(p))
+			if left == 0 {
+				return 0, io.EOF
+			}
+			n := min(len(p), left)
+			left -= n
+			return n, nil
+		})
+		n, err := dl.copyBody(sink, src, &verifier{})
+		if err != nil || n != 64<<10 {
+			t.Fatalf("%T: copied %d bytes (%v)", sink, n, err)
+		}
+		if largest != 4<<10 {
+			t.Fatalf("%T: expected reads of %d bytes, got %d", sink, 4<<10, largest)
+		}
+	}
+}
+
+func benchmarkDownload(b *testing.B, size int, save bool) {
+	payload := make([]byte, size)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	b.Cleanup(srv.Close)
+
+	opts := Options{Save: save, NameTemplate: "{index}.bin"}
+	if save {
+		opts.OutDir = b.TempDir()
+	}
+	dl := New(NewHTTPClient(5*time.Second), metrics.NewAggregator(), opts)
+	entry := urls.Entry{Mirrors: []string{srv.URL + "/f.bin"}, Index: 1}
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dl.DownloadEntry(context.Background(), entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDownloadDiscardSmall(b *testing.B) { benchmarkDownload(b, 4<<10, false) }
+func BenchmarkDownloadDiscardLarge(b *testing.B) { benchmarkDownload(b, 1<<20, false) }
+func BenchmarkDownloadSaveSmall(b *testing.B)    { benchmarkDownload(b, 4<<10, true) }
+func BenchmarkDownloadSaveLarge(b *testing.B)    { benchmarkDownload(b, 1<<20, true) }
+
+func TestDownloadRecordsTransfers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	t.Cleanup(srv.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(5*time.Second), agg, Options{})
+	res, err := dl.Download(context.Background(), srv.URL+"/ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Bytes != 10 || res.Timing.TTFB <= 0 {
+		t.Fatalf("expected bytes and timing on the result, got %+v", res)
+	}
+	if _, err := dl.Download(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Fatalf("expected failure")
+	}
+
+	transfers := agg.Transfers()
+	if len(transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %d", len(transfers))
+	}
+	ok, failed := transfers[0], transfers[1]
+	if ok.Outcome != metrics.FileDownloaded || ok.Bytes != 10 || ok.Host != srv.Listener.Addr().String() || ok.Attempts != 1 {
+		t.Fatalf("unexpected transfer: %+v", ok)
+	}
+	if ok.Connect <= 0 || ok.TTFB < ok.Connect {
+		t.Fatalf("expected connect and TTFB phases, got %+v", ok)
+	}
+	if failed.Outcome != metrics.FileFailed || failed.Err == "" {
+		t.Fatalf("unexpected failed transfer: %+v", failed)
+	}
+}
@@ -2,29 +2,65 @@ package downloader
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// Protocol selects the HTTP version a client negotiates.
+type Protocol string
+
+const (
+	// ProtocolHTTP2 negotiates HTTP/2 over TLS where the server offers it
+	// and falls back to HTTP/1.1 (the default).
+	ProtocolHTTP2 Protocol = "http2"
+	// ProtocolHTTP1 never negotiates HTTP/2.
+	ProtocolHTTP1 Protocol = "http1"
+)
+
+// ParseProtocol validates a protocol name ("http1"/"h1" or "http2"/"h2").
+func ParseProtocol(s string) (Protocol, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "http2", "h2", "":
+		return ProtocolHTTP2, nil
+	case "http1", "h1", "http1.1":
+		return ProtocolHTTP1, nil
+	}
+	return "", fmt.Errorf("unknown protocol %q (want http1 or http2)", s)
+}
+
 // NewHTTPClient returns an HTTP client tuned for high-throughput downloads.
+// timeout bounds the TLS handshake and the wait for response headers, not
+// the body: a whole-request deadline would cut off large downloads, so
+// bodies are guarded by Options.StallTimeout and LowSpeedLimit instead.
 func NewHTTPClient(timeout time.Duration) *http.Client {
+	return NewHTTPClientFor(timeout, ProtocolHTTP2)
+}
+
+// NewHTTPClientFor is NewHTTPClient restricted to the given protocol.
+func NewHTTPClientFor(timeout time.Duration, proto Protocol) *http.Client {
 	transport := &http.Transport{
-		MaxIdleConns:        1024,
-		MaxIdleConnsPerHost: 256,
-		MaxConnsPerHost:     0, // unlimited
-		IdleConnTimeout:     90 * time.Second,
-		DisableCompression:  false,
-		ForceAttemptHTTP2:   true,
-		TLSClientConfig:     &tls.Config{InsecureSkipVerify: false},
+		MaxIdleConns:          1024,
+		MaxIdleConnsPerHost:   256,
+		MaxConnsPerHost:       0, // unlimited
+		IdleConnTimeout:       90 * time.Second,
+		DisableCompression:    false,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: false},
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
 		DialContext: (&net.Dialer{
 			Timeout:   15 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 	}
-
-	return &http.Client{
-		Transport: transport,
-		Timeout:   timeout,
+	if proto == ProtocolHTTP1 {
+		// A non-nil empty map disables the transport's HTTP/2 upgrade.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
 	}
+
+	return &http.Client{Transport: transport}
 }
@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// LengthError reports a body whose size disagrees with what the response
+// announced, typically a server or proxy closing the connection early. It
+// is retried and fails over to the next mirror like a server fault.
+type LengthError struct {
+	Want int64
+	Got  int64
+	// Range is set when a 206 response did not cover the whole object.
+	Range string
+}
+
+func (e *LengthError) Error() string {
+	if e.Range != "" {
+		return fmt.Sprintf("incomplete partial response %q", e.Range)
+	}
+	if e.Got < e.Want {
+		return fmt.Sprintf("short body: received %d of %d bytes", e.Got, e.Want)
+	}
+	return fmt.Sprintf("body length mismatch: received %d bytes, expected %d", e.Got, e.Want)
+}
+
+// expectedLength returns how many body bytes resp announces, or -1 if it
+// does not say. Requests never ask for a range, so a 206 must cover the
+// whole object; anything less is reported as a LengthError.
+func expectedLength(resp *http.Response) (int64, error) {
+	if resp.StatusCode != http.StatusPartialContent {
+		return resp.ContentLength, nil
+	}
+	cr := resp.Header.Get("Content-Range")
+	start, end, total, ok := parseContentRange(cr)
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", cr)
+	}
+	if start != 0 || (total >= 0 && end+1 != total) {
+		return 0, &LengthError{Want: total, Got: end - start + 1, Range: cr}
+	}
+	n := end - start + 1
+	if resp.ContentLength >= 0 && resp.ContentLength != n {
+		return 0, fmt.Errorf("Content-Length %d disagrees with Content-Range %q", resp.ContentLength, cr)
+	}
+	return n, nil
+}
+
+// parseContentRange parses "bytes start-end/total", where total may be
+// "*" (returned as -1).
+func parseContentRange(s string) (start, end, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(s), "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	span, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	first, last, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	var err1, err2 error
+	start, err1 = strconv.ParseInt(first, 10, 64)
+	end, err2 = strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return 0, 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		t, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || t <= end {
+			return 0, 0, 0, false
+		}
+		total = t
+	}
+	return start, end, total, true
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+func TestDownloadRetriesShortBody(t *testing.T) {
+	payload := strings.Repeat("x", 100)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		if hits.Add(1) == 1 {
+			// Announce 100 bytes but hang up after 40.
+			_, _ = w.Write([]byte(payload[:40]))
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	t.Cleanup(srv.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(5*time.Second), agg, Options{Retries: 1})
+	res, err := dl.Download(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if res.Bytes != 100 || hits.Load() != 2 {
+		t.Fatalf("expected a full body on the second attempt, got %d bytes after %d requests", res.Bytes, hits.Load())
+	}
+
+	s := agg.GetSummary()
+	if s.GoodputBytes != 100 || s.TotalBytes != 140 {
+		t.Fatalf("expected 100 goodput bytes of 140 on the wire, got %d of %d", s.GoodputBytes, s.TotalBytes)
+	}
+}
+
+func TestDownloadPartialContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		rng     string
+		body    string
+		wantErr bool
+	}{
+		{"whole object", "bytes 0-4/5", "hello", false},
+		{"unknown total", "bytes 0-4/*", "hello", false},
+		{"missing tail", "bytes 0-4/10", "hello", true},
+		{"missing head", "bytes 5-9/10", "world", true},
+		{"malformed", "bytes five", "hello", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", tt.rng)
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+
+			dl := New(NewHTTPClient(5*time.Second), nil, Options{})
+			_, err := dl.Download(context.Background(), srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLengthErrorClassification(t *testing.T) {
+	err := error(&LengthError{Want: 100, Got: 40})
+	if !shouldFailover(err) || Classify(err) != metrics.ClassShortRead {
+		t.Fatalf("expected a short body to fail over as a short read")
+	}
+	if !strings.Contains(err.Error(), "received 40 of 100 bytes") {
+		t.Fatalf("unexpected message %q", err)
+	}
+}
@@ -3,14 +3,28 @@ package downloader
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+	"github.com/cx009/netperf/internal/urls"
 )
 
 // Manager coordinates concurrent downloads.
 type Manager struct {
 	downloader *Downloader
 	workers    int
+	out        io.Writer
+	outMu      sync.Mutex
+
+	scale     *AutoscaleOptions
+	scaleMu   sync.Mutex
+	lastScale *AutoscaleReport
+
+	failures FailurePolicy
 }
 
 // NewManager constructs a Manager with the specified worker count.
@@ -18,58 +32,302 @@ func NewManager(d *Downloader, workers int) *Manager {
 	if workers < 1 {
 		workers = 1
 	}
-	return &Manager{downloader: d, workers: workers}
+	return &Manager{downloader: d, workers: workers, out: os.Stdout}
+}
+
+// SetOutput redirects the per-download status lines (stdout by default),
+// e.g. to stderr when downloads themselves are streamed to stdout.
+func (m *Manager) SetOutput(w io.Writer) {
+	if w != nil {
+		m.out = w
+	}
+}
+
+// SetAutoscale replaces the fixed worker count with adaptive scaling
+// between opts.Min and opts.Max. It needs the downloader to have an
+// aggregator; without one the fixed count is used.
+func (m *Manager) SetAutoscale(opts AutoscaleOptions) {
+	o := opts.withDefaults()
+	m.scale = &o
+}
+
+// SetFailurePolicy makes runs cancel their remaining downloads once fp is
+// exceeded. The run then returns an *AbortError and, with an aggregator,
+// the summary states the reason.
+func (m *Manager) SetFailurePolicy(fp FailurePolicy) {
+	m.failures = fp.withDefaults()
+}
+
+// Autoscale returns the report of the last autoscaled run, if any.
+func (m *Manager) Autoscale() (AutoscaleReport, bool) {
+	m.scaleMu.Lock()
+	defer m.scaleMu.Unlock()
+	if m.lastScale == nil {
+		return AutoscaleReport{}, false
+	}
+	return *m.lastScale, true
 }
 
 // Run processes the provided URLs with the configured worker pool.
-func (m *Manager) Run(ctx context.Context, urls []string) error {
-	jobs := make(chan string, m.workers*2)
-	var wg sync.WaitGroup
-	var failed atomic.Int32
+func (m *Manager) Run(ctx context.Context, list []string) error {
+	entries := make([]urls.Entry, len(list))
+	for i, u := range list {
+		entries[i] = urls.Entry{Mirrors: []string{u}, Index: i + 1}
+	}
+	return m.RunEntries(ctx, entries)
+}
 
-	for i := 0; i < m.workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case url, ok := <-jobs:
-					if !ok {
-						return
-					}
-					res, err := m.downloader.Download(ctx, url)
-					if err != nil {
-						failed.Add(1)
-						fmt.Printf("[FAIL] %s -> %v\n", url, err)
-						continue
-					}
-					if res.Discarded {
-						fmt.Printf("[OK]   %s (discarded)\n", url)
-					} else {
-						fmt.Printf("[OK]   %s -> %s\n", url, res.Destination)
-					}
-				}
+// RunEntries processes entries, failing over between each entry's mirrors.
+func (m *Manager) RunEntries(ctx context.Context, entries []urls.Entry) error {
+	return m.run(ctx, func(ctx context.Context, jobs chan<- urls.Entry) {
+		for _, e := range entries {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- e:
 			}
-		}()
-	}
+		}
+	})
+}
 
-	go func() {
-		defer close(jobs)
-		for _, u := range urls {
+// RunLoop cycles through entries until ctx is done, keeping every worker
+// busy regardless of the list length. It is meant for fixed-duration
+// measurements such as sweeps; downloads interrupted by ctx are not
+// reported as failures.
+func (m *Manager) RunLoop(ctx context.Context, entries []urls.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	err := m.run(ctx, func(ctx context.Context, jobs chan<- urls.Entry) {
+		for i := 0; ; i = (i + 1) % len(entries) {
 			select {
 			case <-ctx.Done():
 				return
-			case jobs <- u:
+			case jobs <- entries[i]:
 			}
 		}
+	})
+	if ctx.Err() != nil && m.downloader.agg != nil {
+		if n := m.downloader.agg.Files(metrics.FileFailed); n > 0 {
+			return fmt.Errorf("%d downloads failed", n)
+		}
+		return nil
+	}
+	return err
+}
+
+// run drives the worker pool with entries produced by feed, which must
+// return once the context it is given is done.
+func (m *Manager) run(parent context.Context, feed func(ctx context.Context, jobs chan<- urls.Entry)) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	autoscale := m.scale != nil && m.downloader.agg != nil
+	capacity := m.workers
+	if autoscale {
+		capacity = m.scale.Max
+	}
+	jobs := make(chan urls.Entry, capacity*2)
+	p := &pool{ctx: ctx, cancel: cancel, m: m, jobs: jobs}
+
+	var scaled chan AutoscaleReport
+	stop := make(chan struct{})
+	if autoscale {
+		p.resize(m.scale.Min)
+		scaled = make(chan AutoscaleReport, 1)
+		go func() {
+			scaled <- m.autoscale(ctx, p, m.downloader.agg, stop)
+		}()
+	} else {
+		p.resize(m.workers)
+	}
+
+	go func() {
+		defer close(jobs)
+		feed(ctx, jobs)
 	}()
 
-	wg.Wait()
+	p.wg.Wait()
+	close(stop)
+	if autoscale {
+		report := <-scaled
+		m.scaleMu.Lock()
+		m.lastScale = &report
+		m.scaleMu.Unlock()
+	}
 
-	if n := failed.Load(); n > 0 {
+	if reason := p.abortReason(); reason != "" {
+		if agg := m.downloader.agg; agg != nil {
+			agg.SetAborted(reason)
+		}
+		return &AbortError{Reason: reason, Failed: p.budgetFailed.Load()}
+	}
+	if n := p.failed.Load(); n > 0 {
 		return fmt.Errorf("%d downloads failed", n)
 	}
 	return nil
 }
+
+// handle downloads one entry and prints its status line. It reports
+// whether the download failed.
+func (m *Manager) handle(ctx context.Context, entry urls.Entry) bool {
+	url := entry.URL()
+	res, err := m.downloader.DownloadEntry(ctx, entry)
+	if err != nil {
+		m.printf("[FAIL] %s -> %s: %v\n", url, Classify(err), err)
+		return true
+	}
+	via := ""
+	if res.Mirror != "" && res.Mirror != url {
+		via = " via " + res.Mirror
+	}
+	switch {
+	case res.Unchanged:
+		m.printf("[SAME] %s -> %s (not modified)\n", url, res.Destination)
+	case res.Skipped:
+		m.printf("[SKIP] %s -> %s (exists)\n", url, res.Destination)
+	case res.Discarded:
+		m.printf("[OK]   %s (discarded)%s\n", url, via)
+	default:
+		m.printf("[OK]   %s -> %s%s\n", url, res.Destination, via)
+	}
+	return false
+}
+
+// printf writes one status line; workers share m.out, so lines are
+// serialized to keep them whole.
+func (m *Manager) printf(format string, args ...any) {
+	m.outMu.Lock()
+	defer m.outMu.Unlock()
+	fmt.Fprintf(m.out, format, args...)
+}
+
+// pool is a resizable set of workers draining jobs.
+type pool struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	m      *Manager
+	jobs   <-chan urls.Entry
+	wg     sync.WaitGroup
+
+	done   atomic.Int64
+	failed atomic.Int64
+	ids    atomic.Int64
+	// budgetFailed and budgetDone count only downloads that finished
+	// before the run was cancelled, for the failure policy.
+	budgetFailed atomic.Int64
+	budgetDone   atomic.Int64
+
+	mu       sync.Mutex
+	target   int
+	running  int
+	draining bool
+	aborted  string
+}
+
+// resize sets the desired worker count. Extra workers exit after their
+// current download; missing ones are started immediately.
+func (p *pool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining {
+		return
+	}
+	p.target = n
+	for p.running < n {
+		p.running++
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// retire reports whether the calling worker is surplus and should exit.
+func (p *pool) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running > p.target {
+		p.running--
+		return true
+	}
+	return false
+}
+
+// exit records that a worker stopped because the run is over.
+func (p *pool) exit() {
+	p.mu.Lock()
+	p.draining = true
+	p.running--
+	p.mu.Unlock()
+}
+
+func (p *pool) work() {
+	defer p.wg.Done()
+	id := int(p.ids.Add(1))
+	tally := &workerTally{id: id}
+	ctx := withWorker(p.ctx, tally)
+	agg := p.m.downloader.agg
+
+	idleSince := time.Now()
+	for {
+		if p.retire() {
+			return
+		}
+		select {
+		case <-p.ctx.Done():
+			p.exit()
+			return
+		case entry, ok := <-p.jobs:
+			if !ok {
+				p.exit()
+				return
+			}
+			start := time.Now()
+			failed := p.m.handle(ctx, entry)
+			if agg != nil {
+				agg.RecordWorkerJob(id, tally.bytes.Swap(0), time.Since(start), start.Sub(idleSince))
+			}
+			idleSince = time.Now()
+			if failed {
+				p.failed.Add(1)
+			}
+			p.done.Add(1)
+			if p.ctx.Err() == nil {
+				p.account(failed)
+			}
+		}
+	}
+}
+
+// account counts a download against the failure policy and cancels the
+// run the first time the policy is exceeded.
+func (p *pool) account(failed bool) {
+	fp := p.m.failures
+	if !fp.active() {
+		return
+	}
+	n := p.budgetFailed.Load()
+	if failed {
+		n = p.budgetFailed.Add(1)
+	}
+	reason := fp.exceeded(n, p.budgetDone.Add(1))
+	if reason == "" {
+		return
+	}
+	p.mu.Lock()
+	first := p.aborted == ""
+	if first {
+		p.aborted = reason
+	}
+	p.mu.Unlock()
+	if first {
+		p.m.printf("[ABORT] %s, cancelling remaining downloads\n", reason)
+		p.cancel()
+	}
+}
+
+// abortReason returns why the failure policy cancelled the run, if it did.
+func (p *pool) abortReason() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.aborted
+}
@@ -2,8 +2,11 @@ package downloader
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -45,3 +48,194 @@ func TestManagerRunFailure(t *testing.T) {
 		t.Fatalf("expected failure error")
 	}
 }
+
+func TestManagerAutoscaleFindsSaturation(t *testing.T) {
+	// Every request takes a fixed time, so throughput grows with workers
+	// until the server admits no more than four requests at once.
+	sem := make(chan struct{}, 4)
+	payload := make([]byte, 32<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(srv.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(5*time.Second), agg, Options{})
+	mgr := NewManager(dl, 1)
+	mgr.SetOutput(io.Discard)
+	mgr.SetAutoscale(AutoscaleOptions{Min: 1, Max: 64, Interval: 200 * time.Millisecond, Threshold: 0.3})
+
+	list := make([]string, 2000)
+	for i := range list {
+		list[i] = srv.URL
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() {
+		// Stop once the search is over instead of draining the whole list.
+		for agg.Saturation().Workers == 0 && ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	_ = mgr.Run(ctx, list)
+
+	report, ok := mgr.Autoscale()
+	if !ok || !report.Saturated {
+		t.Fatalf("expected a saturation point, got %+v", report)
+	}
+	if report.Workers < 2 || report.Workers > 8 {
+		t.Fatalf("expected saturation near 4 workers, got %d (%+v)", report.Workers, report.Levels)
+	}
+	if agg.GetSummary().Saturation.Workers != report.Workers {
+		t.Fatalf("summary does not carry the saturation point")
+	}
+}
+
+func TestManagerFailurePolicyAborts(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	list := make([]string, 200)
+	for i := range list {
+		list[i] = srv.URL
+	}
+	for name, fp := range map[string]FailurePolicy{
+		"fail-fast":    {FailFast: true},
+		"max-failures": {MaxFailures: 3},
+		"rate":         {MaxFailureRate: 0.5, MinFiles: 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			requests.Store(0)
+			agg := metrics.NewAggregator()
+			dl := New(NewHTTPClient(time.Second), agg, Options{Retries: 0})
+			mgr := NewManager(dl, 1)
+			mgr.SetOutput(io.Discard)
+			mgr.SetFailurePolicy(fp)
+
+			err := mgr.Run(context.Background(), list)
+			var abort *AbortError
+			if !errors.As(err, &abort) {
+				t.Fatalf("expected an abort, got %v", err)
+			}
+			if n := requests.Load(); n >= int64(len(list)) {
+				t.Fatalf("expected the run to stop early, made %d requests", n)
+			}
+			if got := agg.GetSummary().Aborted; got != abort.Reason {
+				t.Fatalf("summary abort reason %q, want %q", got, abort.Reason)
+			}
+		})
+	}
+}
+
+func TestManagerFailurePolicyWithinBudget(t *testing.T) {
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1)%5 == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(time.Second), agg, Options{Retries: 0})
+	mgr := NewManager(dl, 1)
+	mgr.SetOutput(io.Discard)
+	mgr.SetFailurePolicy(FailurePolicy{MaxFailures: 5, MaxFailureRate: 0.25})
+
+	list := make([]string, 20)
+	for i := range list {
+		list[i] = srv.URL
+	}
+	err := mgr.Run(context.Background(), list)
+	var abort *AbortError
+	if err == nil || errors.As(err, &abort) {
+		t.Fatalf("expected plain download failures, got %v", err)
+	}
+	if s := agg.GetSummary(); s.FilesFailed != 4 || s.Aborted != "" {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+}
+
+func TestManagerRecordsWorkerStats(t *testing.T) {
+	payload := make([]byte, 4<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(srv.Close)
+
+	agg := metrics.NewAggregator()
+	dl := New(NewHTTPClient(5*time.Second), agg, Options{})
+	mgr := NewManager(dl, 4)
+	mgr.SetOutput(io.Discard)
+
+	list := make([]string, 40)
+	for i := range list {
+		list[i] = srv.URL
+	}
+	if err := mgr.Run(context.Background(), list); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := agg.GetSummary()
+	if len(s.Workers) == 0 || len(s.Workers) > 4 {
+		t.Fatalf("expected up to 4 workers, got %+v", s.Workers)
+	}
+	var files int
+	var bytes int64
+	for _, w := range s.Workers {
+		files += w.Files
+		bytes += w.Bytes
+		if w.Files > 0 && w.Active <= 0 {
+			t.Fatalf("expected active time for worker %d", w.ID)
+		}
+	}
+	if files != len(list) || bytes != agg.TotalBytes() {
+		t.Fatalf("expected workers to account for %d files and %d bytes, got %d and %d", len(list), agg.TotalBytes(), files, bytes)
+	}
+	if s.Fairness.Workers != len(s.Workers) || s.Fairness.Jain <= 0 || s.Fairness.Jain > 1+1e-9 {
+		t.Fatalf("unexpected fairness: %+v", s.Fairness)
+	}
+}
+
+func TestManagerReportsSharedHTTP2Connections(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write(make([]byte, 4<<10))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	client := NewHTTPClient(5 * time.Second)
+	client.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	agg := metrics.NewAggregator()
+	mgr := NewManager(New(client, agg, Options{}), 4)
+	mgr.SetOutput(io.Discard)
+
+	list := make([]string, 16)
+	for i := range list {
+		list[i] = srv.URL
+	}
+	if err := mgr.Run(context.Background(), list); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := agg.GetSummary(); s.Fairness.SharedConns == 0 {
+		t.Fatalf("expected workers to share an HTTP/2 connection, got %+v", s.Fairness)
+	}
+}
+
+func TestAutoscaleOptionsClampInterval(t *testing.T) {
+	if o := (AutoscaleOptions{Interval: 3 * time.Nanosecond}).withDefaults(); o.Interval != MinAutoscaleInterval {
+		t.Fatalf("expected interval clamped to %v, got %v", MinAutoscaleInterval, o.Interval)
+	}
+}
@@ -0,0 +1,173 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ManifestName is the sidecar file kept in OutDir by incremental mode.
+const ManifestName = ".bandfetch-manifest.jsonl"
+
+// manifestCompactSize is the file size at which openManifest rewrites the
+// manifest keeping only the latest record per URL.
+var manifestCompactSize int64 = 1 << 20
+
+// manifestRecord remembers what was last saved for a URL.
+type manifestRecord struct {
+	URL string `json:"url"`
+	// Path is relative to the manifest's directory, so the output
+	// directory can be moved or reached from another working directory.
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// manifest is an append-only JSON Lines log; the last record for a URL
+// wins. Appending keeps updates cheap and survives interrupted runs; the
+// superseded records are dropped once the file grows past
+// manifestCompactSize.
+type manifest struct {
+	mu      sync.Mutex
+	dir     string
+	f       *os.File
+	records map[string]manifestRecord
+}
+
+func openManifest(dir string) (*manifest, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, ManifestName)
+	records := map[string]manifestRecord{}
+	lines := 0
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			lines++
+			var rec manifestRecord
+			// A torn final line from an interrupted run is simply ignored.
+			if json.Unmarshal(scanner.Bytes(), &rec) == nil && rec.URL != "" {
+				records[rec.URL] = rec
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size() >= manifestCompactSize && lines > len(records) {
+		if err := compactManifest(path, records); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &manifest{dir: dir, f: f, records: records}, nil
+}
+
+// compactManifest replaces the file at path with one line per record,
+// sorted by URL. The rewrite goes through a temporary file so an
+// interrupted compaction leaves the old manifest in place.
+func compactManifest(path string, records map[string]manifestRecord) error {
+	keys := make([]string, 0, len(records))
+	for k := range records {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ManifestName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, k := range keys {
+		line, err := json.Marshal(records[k])
+		if err == nil {
+			w.Write(line)
+			err = w.WriteByte('\n')
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// lookup returns the record for url, with Path resolved against the
+// manifest's directory, if the file it describes is still on disk with the
+// recorded size.
+func (m *manifest) lookup(url string) (manifestRecord, bool) {
+	m.mu.Lock()
+	rec, ok := m.records[url]
+	m.mu.Unlock()
+	if !ok {
+		return manifestRecord{}, false
+	}
+	rec.Path = m.resolve(rec.Path)
+	info, err := os.Stat(rec.Path)
+	if err != nil || info.Size() != rec.Size {
+		return manifestRecord{}, false
+	}
+	return rec, true
+}
+
+// record stores rec, whose Path is the destination as written (relative to
+// the working directory or absolute).
+func (m *manifest) record(rec manifestRecord) error {
+	if rel, err := filepath.Rel(m.dir, rec.Path); err == nil {
+		rec.Path = filepath.ToSlash(rel)
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[rec.URL] = rec
+	_, err = m.f.Write(append(line, '\n'))
+	return err
+}
+
+// resolve returns the location of a recorded path.
+func (m *manifest) resolve(path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
+
+func (m *manifest) close() error {
+	if m == nil {
+		return nil
+	}
+	return m.f.Close()
+}
@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds how long a mirror probe may take.
+const probeTimeout = 5 * time.Second
+
+// probeMirrors issues concurrent HEAD requests and returns the mirrors
+// ordered by response latency. Mirrors that fail the probe keep their
+// relative order after the reachable ones so they still serve as fallbacks.
+func (d *Downloader) probeMirrors(ctx context.Context, mirrors []string) []string {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	latency := make([]time.Duration, len(mirrors))
+	var wg sync.WaitGroup
+	for i, mirror := range mirrors {
+		wg.Add(1)
+		go func(i int, mirror string) {
+			defer wg.Done()
+			latency[i] = d.probe(ctx, mirror)
+		}(i, mirror)
+	}
+	wg.Wait()
+
+	order := make([]int, len(mirrors))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		la, lb := latency[order[a]], latency[order[b]]
+		if la < 0 || lb < 0 {
+			return lb < 0 && la >= 0
+		}
+		return la < lb
+	})
+
+	out := make([]string, len(mirrors))
+	for i, idx := range order {
+		out[i] = mirrors[idx]
+	}
+	return out
+}
+
+// probe returns the time to response headers, or -1 if the mirror is
+// unreachable or answers with a server error.
+func (d *Downloader) probe(ctx context.Context, rawURL string) time.Duration {
+	req, err := d.newRequest(ctx, http.MethodHead, rawURL)
+	if err != nil {
+		return -1
+	}
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return -1
+	}
+	return time.Since(start)
+}
@@ -1,9 +1,13 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
+	"mime"
 	"net/url"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -42,3 +46,99 @@ func sanitize(in string) string {
 	in = strings.ReplaceAll(in, "/", "-")
 	return in
 }
+
+// fileNameFromDisposition returns the filename suggested by a
+// Content-Disposition header, reduced to its final path element.
+func fileNameFromDisposition(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	name := strings.ReplaceAll(params["filename"], "\\", "/")
+	name = path.Base(strings.TrimSpace(name))
+	if name == "." || name == ".." || name == "/" || name == "" {
+		return ""
+	}
+	return sanitize(name)
+}
+
+// NameVars are the values available to a -name-template.
+type NameVars struct {
+	Host     string // URL host, including any port
+	Path     string // URL path without the leading slash
+	Basename string // Content-Disposition, Metalink or URL file name
+	Index    int    // 1-based position of the entry in the list
+}
+
+var namePlaceholders = map[string]func(NameVars) string{
+	"host":     func(v NameVars) string { return v.Host },
+	"path":     func(v NameVars) string { return v.Path },
+	"basename": func(v NameVars) string { return v.Basename },
+	"index":    func(v NameVars) string { return strconv.Itoa(v.Index) },
+}
+
+// ValidateNameTemplate reports unknown placeholders or unbalanced braces.
+func ValidateNameTemplate(tmpl string) error {
+	_, err := RenderName(tmpl, NameVars{Host: "h", Path: "p", Basename: "b", Index: 1})
+	return err
+}
+
+// RenderName expands a template such as "{host}/{path}" or
+// "{index}-{basename}" into a relative output path. Results that would
+// escape the output directory are rejected.
+func RenderName(tmpl string, v NameVars) (string, error) {
+	if tmpl == "" {
+		return safeRelPath(v.Basename)
+	}
+	var b strings.Builder
+	rest := tmpl
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return "", fmt.Errorf("name template %q: unmatched '}'", tmpl)
+			}
+			b.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("name template %q: unmatched '{'", tmpl)
+		}
+		key := rest[open+1 : open+end]
+		fn, ok := namePlaceholders[key]
+		if !ok {
+			return "", fmt.Errorf("name template %q: unknown placeholder {%s}", tmpl, key)
+		}
+		b.WriteString(rest[:open])
+		b.WriteString(fn(v))
+		rest = rest[open+end+1:]
+	}
+	return safeRelPath(b.String())
+}
+
+// safeRelPath normalises a rendered name and refuses absolute paths and
+// parent-directory references.
+func safeRelPath(p string) (string, error) {
+	p = strings.ReplaceAll(p, "\\", "/")
+	if strings.HasPrefix(p, "/") || filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return "", fmt.Errorf("unsafe output path %q: absolute paths are not allowed", p)
+	}
+	var parts []string
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("unsafe output path %q: '..' is not allowed", p)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", errors.New("output name is empty")
+	}
+	return filepath.Join(parts...), nil
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderName(t *testing.T) {
+	vars := NameVars{Host: "a.com", Path: "x/data.bin", Basename: "data.bin", Index: 7}
+	cases := map[string]string{
+		"":                   "data.bin",
+		"{host}/{path}":      filepath.Join("a.com", "x", "data.bin"),
+		"{index}-{basename}": "7-data.bin",
+	}
+	for tmpl, want := range cases {
+		got, err := RenderName(tmpl, vars)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tmpl, err)
+		}
+		if got != want {
+			t.Fatalf("%q: expected %s, got %s", tmpl, want, got)
+		}
+	}
+}
+
+func TestRenderNameRejectsUnsafePaths(t *testing.T) {
+	unsafe := []NameVars{
+		{Path: "../etc/passwd"},
+		{Path: "x/../../y"},
+		{Path: "/abs"},
+		{Path: `..\win`},
+	}
+	for _, v := range unsafe {
+		if got, err := RenderName("{path}", v); err == nil {
+			t.Fatalf("expected %q to be rejected, got %s", v.Path, got)
+		}
+	}
+	if err := ValidateNameTemplate("{nope}"); err == nil {
+		t.Fatalf("expected unknown placeholder error")
+	}
+}
+
+func TestFileNameFromDisposition(t *testing.T) {
+	cases := map[string]string{
+		`attachment; filename="report.pdf"`:           "report.pdf",
+		`attachment; filename="../../etc/passwd"`:     "passwd",
+		`attachment; filename*=UTF-8''na%C3%AFve.txt`: "naïve.txt",
+		`inline`: "",
+	}
+	for header, want := range cases {
+		if got := fileNameFromDisposition(header); got != want {
+			t.Fatalf("%q: expected %q, got %q", header, want, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package downloader
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxComponentBytes keeps path components under common filesystem limits
+// (255 bytes) with room for the ".part" suffix and a rename counter.
+const maxComponentBytes = 200
+
+// windowsReserved are device names that cannot be used as file names on
+// Windows, with or without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// preservedPath mirrors the URL's host and path as a relative output path,
+// e.g. https://a.com:8443/x/y%20z.bin -> a.com_8443/x/y z.bin. Segments are
+// percent-decoded individually so an encoded "/" cannot introduce a
+// directory, and every component is passed through sanitizeComponent. A
+// query string adds a short digest to the file name, so ?a=1 and ?a=2 land
+// in different files.
+func preservedPath(rawURL, base string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", errors.New("URL has no host")
+	}
+
+	parts := []string{sanitizeComponent(u.Host)}
+	segments := strings.Split(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	for i, seg := range segments {
+		if seg == "" {
+			if i == len(segments)-1 {
+				parts = append(parts, sanitizeComponent(base))
+			}
+			continue
+		}
+		if dec, err := url.PathUnescape(seg); err == nil {
+			seg = dec
+		}
+		parts = append(parts, sanitizeComponent(seg))
+	}
+	if len(parts) == 1 {
+		parts = append(parts, sanitizeComponent(base))
+	}
+	if u.RawQuery != "" {
+		last := len(parts) - 1
+		parts[last] = sanitizeComponent(withDigest(parts[last], u.RawQuery))
+	}
+	return filepath.Join(parts...), nil
+}
+
+// sanitizeComponent makes a single path element safe on every platform:
+// separators, control and reserved characters become "_", trailing dots
+// and spaces are trimmed (so "." and ".." collapse to "_"), Windows device
+// names are prefixed, and overlong names are shortened with a hash suffix
+// so distinct inputs stay distinct.
+func sanitizeComponent(in string) string {
+	var b strings.Builder
+	for _, r := range in {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			b.WriteByte('_')
+		case strings.ContainsRune(`/\<>:"|?*`, r):
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	out := strings.TrimRight(b.String(), ". ")
+	if out == "" {
+		out = "_"
+	}
+
+	stem := out
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	if windowsReserved[strings.ToUpper(stem)] {
+		out = "_" + out
+	}
+
+	if len(out) > maxComponentBytes {
+		out = shorten(out)
+	}
+	return out
+}
+
+// withDigest inserts "~" and a digest of s before name's extension, e.g.
+// data.bin -> data~1a2b3c4d.bin.
+func withDigest(name, s string) string {
+	ext := shortExt(name)
+	return strings.TrimSuffix(name, ext) + digest(s) + ext
+}
+
+// digest is a short, stable "~hex" tag for s.
+func digest(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return "~" + hex.EncodeToString(sum[:4])
+}
+
+// shortExt is name's extension, or "" when it is too long to be one.
+func shortExt(name string) string {
+	ext := filepath.Ext(name)
+	if len(ext) > 16 {
+		return ""
+	}
+	return ext
+}
+
+// shorten truncates name to maxComponentBytes, keeping a short extension
+// and appending a digest of the full name.
+func shorten(name string) string {
+	suffix := digest(name)
+	ext := shortExt(name)
+	keep := maxComponentBytes - len(suffix) - len(ext)
+	head := name[:keep]
+	for !utf8.ValidString(head) {
+		head = head[:len(head)-1]
+	}
+	return head + suffix + ext
+}
@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreservedPath(t *testing.T) {
+	cases := map[string]string{
+		"https://a.com/x/data.bin":             filepath.Join("a.com", "x", "data.bin"),
+		"https://a.com:8443/x/y%20z.bin":       filepath.Join("a.com_8443", "x", "y z.bin"),
+		"https://a.com/dir%2Fsneaky/file":      filepath.Join("a.com", "dir_sneaky", "file"),
+		"https://a.com/x/%2e%2e/file":          filepath.Join("a.com", "x", "_", "file"),
+		"https://a.com/docs/":                  filepath.Join("a.com", "docs", "index.bin"),
+		"https://a.com":                        filepath.Join("a.com", "index.bin"),
+		"https://a.com/con/aux.txt/nul.tar.gz": filepath.Join("a.com", "_con", "_aux.txt", "_nul.tar.gz"),
+		"https://a.com/x/data.bin?a=1":         filepath.Join("a.com", "x", "data"+digest("a=1")+".bin"),
+		"https://a.com/?a=1":                   filepath.Join("a.com", "index"+digest("a=1")+".bin"),
+	}
+	for raw, want := range cases {
+		got, err := preservedPath(raw, "index.bin")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", raw, err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %s, got %s", raw, want, got)
+		}
+	}
+}
+
+func TestPreservedPathQueryDistinct(t *testing.T) {
+	a, _ := preservedPath("https://a.com/get?id=1", "index.bin")
+	b, _ := preservedPath("https://a.com/get?id=2", "index.bin")
+	if a == b {
+		t.Fatalf("expected distinct paths for distinct queries, both %s", a)
+	}
+}
+
+func TestSanitizeComponentLongNames(t *testing.T) {
+	a := sanitizeComponent(strings.Repeat("a", 300) + ".bin")
+	b := sanitizeComponent(strings.Repeat("a", 301) + ".bin")
+	if len(a) > maxComponentBytes || len(b) > maxComponentBytes {
+		t.Fatalf("expected components to be shortened, got %d and %d bytes", len(a), len(b))
+	}
+	if a == b {
+		t.Fatalf("expected distinct long names to stay distinct")
+	}
+	if !strings.HasSuffix(a, ".bin") {
+		t.Fatalf("expected extension to be kept, got %s", a)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package downloader
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// preallocate reserves size bytes for f with fallocate(2). Filesystems that
+// do not support it fall back to leaving the file sparse.
+func preallocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return nil
+	}
+	return err
+}
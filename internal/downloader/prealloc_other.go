@@ -0,0 +1,11 @@
+//go:build !linux
+
+package downloader
+
+import "os"
+
+// preallocate is a no-op where fallocate(2) is unavailable; extending the
+// file with Truncate would only create a sparse file.
+func preallocate(f *os.File, size int64) error {
+	return nil
+}
@@ -1,84 +1,304 @@
 package downloader
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/cx009/netperf/internal/urls"
 )
 
-// sinkHandle represents a target for a download.
-type sinkHandle struct {
-	writer      io.WriteCloser
-	finalize    func(success bool) error
-	destination string
-	discarded   bool
+// Sink receives the body of a single download attempt. Exactly one of
+// Commit or Abort is called once the body has been consumed.
+type Sink interface {
+	io.Writer
+	// Commit is called after the body was received and verified.
+	Commit() error
+	// Abort discards whatever was written; it is called on any failure.
+	Abort() error
+	// Destination describes where committed data lives, or "" if nowhere.
+	Destination() string
 }
 
-func newDiscardSink() (*sinkHandle, error) {
-	return &sinkHandle{
-		writer:    nopCloser{Writer: io.Discard},
-		finalize:  func(bool) error { return nil },
-		discarded: true,
-	}, nil
+// SinkRequest describes the download a sink is being opened for.
+type SinkRequest struct {
+	// URL is the mirror serving this attempt.
+	URL   string
+	Entry urls.Entry
+	// Name is the resolved relative output name (see Options.NameTemplate).
+	Name string
+	// ContentLength is the announced body size, or -1 if unknown.
+	ContentLength int64
+	Header        http.Header
 }
 
-func newFileSink(outDir, fileName string) (*sinkHandle, error) {
-	finalPath := filepath.Join(outDir, fileName)
-	tmpPath := finalPath + ".part"
+// SinkFactory opens a Sink for each download attempt. Factories are shared
+// by all workers and must be safe for concurrent use.
+type SinkFactory interface {
+	Open(req SinkRequest) (Sink, error)
+}
 
-	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return nil, err
+// SinkChecker is an optional SinkFactory extension reporting a failure
+// that dooms every further download, such as a broken output stream. The
+// downloader checks it before each attempt and gives up without a request.
+type SinkChecker interface {
+	Err() error
+}
+
+// SinkFactoryFunc adapts a function to SinkFactory.
+type SinkFactoryFunc func(req SinkRequest) (Sink, error)
+
+// Open calls f(req).
+func (f SinkFactoryFunc) Open(req SinkRequest) (Sink, error) { return f(req) }
+
+// SkipError is returned by a factory to decline a download without failing
+// it, for example because the destination already exists.
+type SkipError struct {
+	Destination string
+}
+
+func (e *SkipError) Error() string {
+	return fmt.Sprintf("%s exists; skipped", e.Destination)
+}
+
+// ConflictPolicy decides what happens when a destination file exists.
+type ConflictPolicy string
+
+const (
+	// ConflictOverwrite replaces the existing file (the historical behaviour).
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSkip leaves the existing file and reports the download as skipped.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictRename saves under the first free "name-N.ext".
+	ConflictRename ConflictPolicy = "rename"
+	// ConflictFail fails the download with ErrDestinationExists.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ParseConflictPolicy validates a -on-conflict value.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case ConflictOverwrite, ConflictSkip, ConflictRename, ConflictFail:
+		return p, nil
+	case "":
+		return ConflictOverwrite, nil
 	}
+	return "", fmt.Errorf("unknown conflict policy %q (want overwrite, skip, rename or fail)", s)
+}
 
-	f, err := os.Create(tmpPath)
-	if err != nil {
+// ErrDestinationExists is returned under ConflictFail. It is not retried.
+var ErrDestinationExists = errors.New("destination already exists")
+
+// maxRenameAttempts bounds the search for a free name under ConflictRename.
+const maxRenameAttempts = 10000
+
+// DiscardSinks returns a factory whose sinks drop all data.
+func DiscardSinks() SinkFactory {
+	return SinkFactoryFunc(func(SinkRequest) (Sink, error) {
+		return NewDiscardSink(), nil
+	})
+}
+
+// FileSinkOptions tune the file sinks created by FileSinks.
+type FileSinkOptions struct {
+	OnConflict ConflictPolicy
+	// Preallocate reserves Content-Length bytes up front (fallocate on
+	// Linux) to reduce fragmentation and surface ENOSPC early.
+	Preallocate bool
+	// Fsync flushes file data before the rename and the directory entry
+	// after it so completed downloads survive a crash.
+	Fsync bool
+}
+
+// FileSinks returns a factory that saves each download as req.Name under dir.
+func FileSinks(dir string, opts FileSinkOptions) SinkFactory {
+	return SinkFactoryFunc(func(req SinkRequest) (Sink, error) {
+		sink, err := NewFileSink(dir, req.Name, opts.OnConflict)
+		if err != nil {
+			return nil, err
+		}
+		fs := sink.(*fileSink)
+		fs.fsync = opts.Fsync
+		if opts.Preallocate && req.ContentLength > 0 {
+			if err := preallocate(fs.f, req.ContentLength); err != nil {
+				fs.Abort()
+				return nil, err
+			}
+			fs.prealloc = req.ContentLength
+		}
+		return fs, nil
+	})
+}
+
+// discardSink drops everything written to it.
+type discardSink struct{}
+
+// NewDiscardSink returns a sink that drops everything written to it.
+func NewDiscardSink() Sink { return discardSink{} }
+
+func (discardSink) Write(p []byte) (int, error) { return len(p), nil }
+func (discardSink) Commit() error               { return nil }
+func (discardSink) Abort() error                { return nil }
+func (discardSink) Destination() string         { return "" }
+
+// ReadFrom drains r without passing each chunk through Write.
+func (discardSink) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(io.Discard, r)
+}
+
+// fileSink writes to "<name>.part" and renames it into place on Commit.
+type fileSink struct {
+	f         *os.File
+	tmpPath   string
+	finalPath string
+	reserved  bool
+	fsync     bool
+	prealloc  int64
+	written   int64
+}
+
+// NewFileSink opens a file sink for dir/name. name may contain
+// subdirectories, which are created as needed. Under ConflictRename and
+// ConflictFail the final name is reserved up front with an empty
+// placeholder so concurrent workers cannot claim the same path; under
+// ConflictSkip an existing file yields a *SkipError.
+func NewFileSink(dir, name string, policy ConflictPolicy) (Sink, error) {
+	finalPath := filepath.Join(dir, name)
+
+	if err := os.MkdirAll(filepath.Dir(finalPath), 0o755); err != nil {
 		return nil, err
 	}
 
-	finalize := func(success bool) error {
-		if success {
-			return os.Rename(tmpPath, finalPath)
+	reserved := false
+	switch policy {
+	case ConflictSkip:
+		if _, err := os.Stat(finalPath); err == nil {
+			return nil, &SkipError{Destination: finalPath}
 		}
-		if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
-			return err
+	case ConflictFail:
+		if err := reserve(finalPath); err != nil {
+			if os.IsExist(err) {
+				return nil, fmt.Errorf("%s: %w", finalPath, ErrDestinationExists)
+			}
+			return nil, err
 		}
-		return nil
+		reserved = true
+	case ConflictRename:
+		p, err := reserveFree(finalPath)
+		if err != nil {
+			return nil, err
+		}
+		finalPath = p
+		reserved = true
 	}
 
-	return &sinkHandle{
-		writer:      f,
-		finalize:    finalize,
-		destination: finalPath,
-		discarded:   false,
+	tmpPath := finalPath + ".part"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		if reserved {
+			os.Remove(finalPath)
+		}
+		return nil, err
+	}
+
+	return &fileSink{
+		f:         f,
+		tmpPath:   tmpPath,
+		finalPath: finalPath,
+		reserved:  reserved,
 	}, nil
 }
 
-func (s *sinkHandle) closeWriter() error {
-	if s == nil || s.writer == nil {
-		return nil
+func (s *fileSink) Write(p []byte) (int, error) {
+	n, err := s.f.Write(p)
+	s.written += int64(n)
+	return n, err
+}
+
+// ReadFrom writes r straight to the temp file.
+func (s *fileSink) ReadFrom(r io.Reader) (int64, error) {
+	return readFrom(s, r)
+}
+
+func (s *fileSink) Destination() string { return s.finalPath }
+
+// Commit closes the temp file and promotes it to the final name.
+func (s *fileSink) Commit() error {
+	if err := s.finish(); err != nil {
+		s.f.Close()
+		s.cleanup()
+		return err
+	}
+	if err := s.f.Close(); err != nil {
+		s.cleanup()
+		return err
+	}
+	if err := os.Rename(s.tmpPath, s.finalPath); err != nil {
+		s.cleanup()
+		return err
 	}
-	return s.writer.Close()
+	if s.fsync {
+		return syncDir(filepath.Dir(s.finalPath))
+	}
+	return nil
 }
 
-// finalizeFailure ensures temp files are removed when the caller handles failure paths.
-func (s *sinkHandle) finalizeFailure() {
-	if s == nil || s.finalize == nil {
-		return
+// finish trims unused preallocated space and flushes data if requested.
+func (s *fileSink) finish() error {
+	if s.prealloc > 0 && s.written != s.prealloc {
+		if err := s.f.Truncate(s.written); err != nil {
+			return err
+		}
+	}
+	if s.fsync {
+		return s.f.Sync()
 	}
-	_ = s.finalize(false)
+	return nil
+}
+
+// Abort closes and removes the temp file and any reservation.
+func (s *fileSink) Abort() error {
+	s.f.Close()
+	return s.cleanup()
 }
 
-// finalizeSuccess promotes the temp file on successful downloads.
-func (s *sinkHandle) finalizeSuccess() error {
-	if s == nil || s.finalize == nil {
-		return nil
+func (s *fileSink) cleanup() error {
+	if s.reserved {
+		os.Remove(s.finalPath)
 	}
-	return s.finalize(true)
+	if err := os.Remove(s.tmpPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
-// nopCloser adapts an io.Writer to io.WriteCloser.
-type nopCloser struct {
-	io.Writer
+// reserve exclusively creates an empty placeholder at path.
+func reserve(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
 }
 
-func (n nopCloser) Close() error { return nil }
+// reserveFree reserves path or the first free "name-N.ext" variant of it.
+func reserveFree(path string) (string, error) {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; i <= maxRenameAttempts; i++ {
+		err := reserve(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	return "", fmt.Errorf("%s: no free name after %d attempts", path, maxRenameAttempts)
+}
@@ -1,42 +1,46 @@
 package downloader
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewDiscardSink(t *testing.T) {
-	sink, err := newDiscardSink()
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if !sink.discarded {
+	sink := NewDiscardSink()
+	if sink.Destination() != "" {
 		t.Fatalf("expected sink to discard")
 	}
-	if err := sink.closeWriter(); err != nil {
-		t.Fatalf("close writer: %v", err)
+	if _, err := sink.Write([]byte("data")); err != nil {
+		t.Fatalf("write error: %v", err)
 	}
-	if err := sink.finalizeSuccess(); err != nil {
-		t.Fatalf("finalize success: %v", err)
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
 	}
 }
 
 func TestNewFileSinkSuccess(t *testing.T) {
 	dir := t.TempDir()
-	sink, err := newFileSink(dir, "file.bin")
+	sink, err := NewFileSink(dir, "file.bin", ConflictOverwrite)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	data := []byte("hello world")
-	if _, err := sink.writer.Write(data); err != nil {
+	if _, err := sink.Write(data); err != nil {
 		t.Fatalf("write error: %v", err)
 	}
-	if err := sink.closeWriter(); err != nil {
-		t.Fatalf("close error: %v", err)
+	if _, err := os.Stat(filepath.Join(dir, "file.bin.part")); err != nil {
+		t.Fatalf("expected data to be staged in a .part file: %v", err)
 	}
-	if err := sink.finalizeSuccess(); err != nil {
-		t.Fatalf("finalize success: %v", err)
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
 	}
 	if _, err := os.Stat(filepath.Join(dir, "file.bin")); err != nil {
 		t.Fatalf("expected final file to exist: %v", err)
@@ -48,17 +52,16 @@ func TestNewFileSinkSuccess(t *testing.T) {
 
 func TestNewFileSinkFailure(t *testing.T) {
 	dir := t.TempDir()
-	sink, err := newFileSink(dir, "file.bin")
+	sink, err := NewFileSink(dir, "file.bin", ConflictOverwrite)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if _, err := sink.writer.Write([]byte("data")); err != nil {
+	if _, err := sink.Write([]byte("data")); err != nil {
 		t.Fatalf("write error: %v", err)
 	}
-	if err := sink.closeWriter(); err != nil {
-		t.Fatalf("close error: %v", err)
+	if err := sink.Abort(); err != nil {
+		t.Fatalf("abort: %v", err)
 	}
-	sink.finalizeFailure()
 	if _, err := os.Stat(filepath.Join(dir, "file.bin.part")); !os.IsNotExist(err) {
 		t.Fatalf("temporary file should be cleaned up")
 	}
@@ -66,3 +69,120 @@ func TestNewFileSinkFailure(t *testing.T) {
 		t.Fatalf("final file should not exist")
 	}
 }
+
+func TestFileSinkCommitRenameFailureRemovesPart(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(dir, "file.bin", ConflictOverwrite)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A non-empty directory in the way makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(dir, "file.bin", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Commit(); err == nil {
+		t.Fatalf("expected rename error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "file.bin.part")); !os.IsNotExist(err) {
+		t.Fatalf("temporary file should be removed, got %v", err)
+	}
+}
+
+func TestNewFileSinkConflictPolicies(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "file.bin")
+	if err := os.WriteFile(existing, []byte("old"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+
+	var skip *SkipError
+	if _, err := NewFileSink(dir, "file.bin", ConflictSkip); !errors.As(err, &skip) || skip.Destination != existing {
+		t.Fatalf("expected skip, got %v", err)
+	}
+	if _, err := NewFileSink(dir, "file.bin", ConflictFail); !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("expected ErrDestinationExists, got %v", err)
+	}
+
+	sink, err := NewFileSink(dir, "file.bin", ConflictRename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sink.Destination() != filepath.Join(dir, "file-1.bin") {
+		t.Fatalf("expected renamed destination, got %s", sink.Destination())
+	}
+	_ = sink.Abort()
+	if _, err := os.Stat(sink.Destination()); !os.IsNotExist(err) {
+		t.Fatalf("reservation should be released on failure")
+	}
+
+	data, _ := os.ReadFile(existing)
+	if string(data) != "old" {
+		t.Fatalf("existing file must be untouched, got %q", data)
+	}
+}
+
+// memorySink collects committed bodies in memory.
+type memorySink struct {
+	bytes.Buffer
+	mu      *sync.Mutex
+	done    map[string][]byte
+	url     string
+	aborted bool
+}
+
+func (m *memorySink) Commit() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done[m.url] = m.Bytes()
+	return nil
+}
+
+func (m *memorySink) Abort() error        { m.aborted = true; return nil }
+func (m *memorySink) Destination() string { return "mem://" + m.url }
+
+func TestCustomSinkFactory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("in-memory"))
+	}))
+	t.Cleanup(srv.Close)
+
+	var mu sync.Mutex
+	done := map[string][]byte{}
+	factory := SinkFactoryFunc(func(req SinkRequest) (Sink, error) {
+		return &memorySink{mu: &mu, done: done, url: req.URL}, nil
+	})
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{Sink: factory})
+
+	res, err := dl.Download(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Discarded || res.Destination != "mem://"+srv.URL {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if string(done[srv.URL]) != "in-memory" {
+		t.Fatalf("expected body in memory sink, got %q", done[srv.URL])
+	}
+}
+
+func TestFileSinksPreallocateTrimsShortBody(t *testing.T) {
+	dir := t.TempDir()
+	factory := FileSinks(dir, FileSinkOptions{Preallocate: true, Fsync: true})
+	sink, err := factory.Open(SinkRequest{Name: "file.bin", ContentLength: 1 << 20})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := sink.Write([]byte("short")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "file.bin"))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Size() != 5 {
+		t.Fatalf("expected preallocated space to be trimmed, got %d bytes", info.Size())
+	}
+}
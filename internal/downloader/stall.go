@@ -0,0 +1,156 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// DefaultStallTimeout is how long a body may deliver no bytes when
+// Options.StallTimeout is 0.
+const DefaultStallTimeout = 30 * time.Second
+
+// NoStallTimeout disables the stall check when used as
+// Options.StallTimeout; any negative value does the same.
+const NoStallTimeout time.Duration = -1
+
+// DefaultLowSpeedTime is how long a transfer may stay below
+// Options.LowSpeedLimit when Options.LowSpeedTime is 0.
+const DefaultLowSpeedTime = 30 * time.Second
+
+// StallError reports a body transfer that stopped delivering data, or
+// stayed below the low-speed limit for too long. It is retried and fails
+// over to the next mirror like a server fault.
+type StallError struct {
+	// Idle is how long no bytes arrived; 0 for a low-speed abort.
+	Idle time.Duration
+	// Rate is the observed speed in bytes/s over Window, below Limit.
+	Rate   float64
+	Limit  int64
+	Window time.Duration
+}
+
+func (e *StallError) Error() string {
+	if e.Idle > 0 {
+		return fmt.Sprintf("transfer stalled: no data for %v", e.Idle)
+	}
+	return fmt.Sprintf("transfer too slow: %s/s below %s/s for %v",
+		metrics.HumanBytes(e.Rate), metrics.HumanBytes(float64(e.Limit)), e.Window)
+}
+
+// watchdog aborts a body transfer through its request context when no
+// bytes arrive for stall, or when the speed stays below limit bytes/s for
+// window. Unlike a whole-request deadline, it never cuts off a large
+// download that is still making progress.
+type watchdog struct {
+	stall  time.Duration
+	limit  int64
+	window time.Duration
+	cancel context.CancelCauseFunc
+
+	n    atomic.Int64
+	done chan struct{}
+}
+
+// watch starts a watchdog for one attempt, or returns nil if both limits
+// are disabled.
+func (d *Downloader) watch(cancel context.CancelCauseFunc) *watchdog {
+	stall := d.opts.StallTimeout
+	if stall == 0 {
+		stall = DefaultStallTimeout
+	}
+	if stall < 0 && d.opts.LowSpeedLimit <= 0 {
+		return nil
+	}
+	w := &watchdog{
+		stall:  stall,
+		limit:  d.opts.LowSpeedLimit,
+		window: d.opts.LowSpeedTime,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if w.limit > 0 && w.window <= 0 {
+		w.window = DefaultLowSpeedTime
+	}
+	go w.run()
+	return w
+}
+
+// wrap counts the bytes read from r.
+func (w *watchdog) wrap(r io.Reader) io.Reader {
+	return readerFunc(func(p []byte) (int, error) {
+		n, err := r.Read(p)
+		w.n.Add(int64(n))
+		return n, err
+	})
+}
+
+// stop ends the watchdog once the copy has returned.
+func (w *watchdog) stop() {
+	close(w.done)
+}
+
+func (w *watchdog) run() {
+	tick := w.stall
+	if w.limit > 0 && (tick <= 0 || w.window < tick) {
+		tick = w.window
+	}
+	tick = min(max(tick/4, 10*time.Millisecond), time.Second)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	now := time.Now()
+	lastN, lastProgress := int64(0), now
+	var slowSince time.Time
+	var slowN int64
+	prevTick, prevN := now, int64(0)
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case now = <-ticker.C:
+		}
+		n := w.n.Load()
+
+		if n != lastN {
+			lastN, lastProgress = n, now
+		} else if w.stall > 0 && now.Sub(lastProgress) >= w.stall {
+			w.cancel(&StallError{Idle: now.Sub(lastProgress)})
+			return
+		}
+
+		if w.limit > 0 {
+			// The transfer is slow from the first tick below the limit
+			// until the average since then recovers above it.
+			if slowSince.IsZero() {
+				if speed(n-prevN, now.Sub(prevTick)) < float64(w.limit) {
+					slowSince, slowN = prevTick, prevN
+				}
+			} else if rate := speed(n-slowN, now.Sub(slowSince)); rate >= float64(w.limit) {
+				slowSince = time.Time{}
+			} else if now.Sub(slowSince) >= w.window {
+				w.cancel(&StallError{Rate: rate, Limit: w.limit, Window: w.window})
+				return
+			}
+		}
+		prevTick, prevN = now, n
+	}
+}
+
+// speed is n bytes over d in bytes/s.
+func speed(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// readerFunc adapts a function to io.Reader.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// trickle serves n chunks of size bytes, one every interval.
+func trickle(n, size int, interval time.Duration) http.HandlerFunc {
+	chunk := make([]byte, size)
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < n; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}
+}
+
+func TestDownloadOutlivesHeaderTimeoutWhileProgressing(t *testing.T) {
+	// The body takes well over the client timeout but never stalls.
+	srv := httptest.NewServer(trickle(12, 1024, 50*time.Millisecond))
+	t.Cleanup(srv.Close)
+
+	dl := New(NewHTTPClient(200*time.Millisecond), nil, Options{StallTimeout: 300 * time.Millisecond})
+	res, err := dl.Download(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("expected a slow but steady download to succeed, got %v", err)
+	}
+	if res.Bytes != 12*1024 {
+		t.Fatalf("expected %d bytes, got %d", 12*1024, res.Bytes)
+	}
+}
+
+func TestDownloadLowSpeedAbort(t *testing.T) {
+	// About 2 KB/s against a 64 KB/s floor.
+	srv := httptest.NewServer(trickle(100, 100, 50*time.Millisecond))
+	t.Cleanup(srv.Close)
+
+	dl := New(NewHTTPClient(time.Second), nil, Options{LowSpeedLimit: 64 << 10, LowSpeedTime: 300 * time.Millisecond})
+	start := time.Now()
+	_, err := dl.Download(context.Background(), srv.URL)
+	var stall *StallError
+	if !errors.As(err, &stall) {
+		t.Fatalf("expected a low-speed abort, got %v", err)
+	}
+	if stall.Idle != 0 || stall.Rate >= 64<<10 || stall.Window != 300*time.Millisecond {
+		t.Fatalf("unexpected stall: %+v", stall)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the abort within the window, took %v", elapsed)
+	}
+}
+
+func TestDownloadRetriesAfterStall(t *testing.T) {
+	var hits atomic.Int32
+	payload := []byte("finally")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(srv.Close)
+
+	dl := New(NewHTTPClient(time.Second), nil, Options{Retries: 1, StallTimeout: 100 * time.Millisecond})
+	res, err := dl.Download(context.Background(), srv.URL)
+	if err != nil || res.Bytes != int64(len(payload)) {
+		t.Fatalf("expected the retry to succeed, got %d bytes, %v", res.Bytes, err)
+	}
+}
+
+func TestWatchDefaultsStallTimeout(t *testing.T) {
+	_, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	w := New(nil, nil, Options{}).watch(cancel)
+	if w == nil || w.stall != DefaultStallTimeout {
+		t.Fatalf("expected zero options to watch for stalls of %v, got %+v", DefaultStallTimeout, w)
+	}
+	w.stop()
+
+	if w := New(nil, nil, Options{StallTimeout: NoStallTimeout}).watch(cancel); w != nil {
+		t.Fatalf("expected a negative stall timeout to disable the watchdog")
+	}
+}
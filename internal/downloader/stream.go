@@ -0,0 +1,253 @@
+package downloader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cx009/netperf/internal/urls"
+)
+
+// EntryFinisher is an optional SinkFactory extension notified once an entry
+// has finished for good, after all retries and mirrors.
+type EntryFinisher interface {
+	FinishEntry(entry urls.Entry, err error)
+}
+
+// ErrStreamBroken is returned once a transfer that was already being
+// written to the stream fails, since the output can no longer be trusted.
+var ErrStreamBroken = errors.New("output stream corrupted by a failed transfer")
+
+// Stream concatenates downloads onto a single writer (typically stdout) in
+// list order (urls.Entry.Index), even though workers finish out of order.
+// The entry at the head of the queue is written through directly; later
+// entries are spooled to temporary files and flushed when their turn comes.
+// Entries without an index are written through as they arrive.
+//
+// mu only guards the queue bookkeeping. Whoever holds the head (active)
+// writes to w without it, so workers spooling later entries never wait on
+// a slow reader of the stream.
+type Stream struct {
+	w        io.Writer
+	spoolDir string
+
+	out sync.Mutex // serializes writes to w
+
+	mu     sync.Mutex
+	next   int              // index of the entry currently allowed to write
+	active bool             // a sink or a drain owns the output
+	ready  map[int]*os.File // finished entries waiting; nil for failures
+	err    error
+}
+
+// NewStream returns a Stream writing to w, spooling out-of-order bodies
+// in spoolDir ("" for the system temp directory).
+func NewStream(w io.Writer, spoolDir string) *Stream {
+	return &Stream{w: w, spoolDir: spoolDir, next: 1, ready: map[int]*os.File{}}
+}
+
+// Open implements SinkFactory.
+func (s *Stream) Open(req SinkRequest) (Sink, error) {
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return &streamSink{stream: s, index: req.Entry.Index}, nil
+}
+
+// Err implements SinkChecker: once the stream is broken, the remaining
+// entries are failed without being requested.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// FinishEntry implements EntryFinisher so failed entries do not hold up
+// the ones behind them.
+func (s *Stream) FinishEntry(entry urls.Entry, err error) {
+	if err == nil || entry.Index == 0 {
+		return
+	}
+	s.mu.Lock()
+	if entry.Index < s.next {
+		s.mu.Unlock()
+		return
+	}
+	s.ready[entry.Index] = nil
+	own := s.claimLocked()
+	s.mu.Unlock()
+	if own {
+		s.drain()
+	}
+}
+
+// claimLocked takes the output for a drain if it is free and the head
+// entry has finished. Callers hold s.mu.
+func (s *Stream) claimLocked() bool {
+	if s.active {
+		return false
+	}
+	if _, ok := s.ready[s.next]; !ok {
+		return false
+	}
+	s.active = true
+	return true
+}
+
+// drain flushes consecutive finished entries and then releases the
+// output. The caller owns the output and does not hold s.mu.
+func (s *Stream) drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		spool, ok := s.ready[s.next]
+		if !ok {
+			s.active = false
+			return
+		}
+		delete(s.ready, s.next)
+		s.next++
+		if spool == nil {
+			continue
+		}
+		broken := s.err != nil
+		s.mu.Unlock()
+		err := s.flush(spool, !broken)
+		s.mu.Lock()
+		s.failLocked(err)
+	}
+}
+
+// flush copies a spool to w, if write is set, and removes it.
+func (s *Stream) flush(spool *os.File, write bool) error {
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	if !write {
+		return nil
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.out.Lock()
+	defer s.out.Unlock()
+	_, err := io.Copy(s.w, spool)
+	return err
+}
+
+// write sends p straight to w.
+func (s *Stream) write(p []byte) (int, error) {
+	s.out.Lock()
+	defer s.out.Unlock()
+	return s.w.Write(p)
+}
+
+// fail records the first error that breaks the stream.
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failLocked(err)
+}
+
+func (s *Stream) failLocked(err error) {
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// streamSink is one attempt at one entry.
+type streamSink struct {
+	stream  *Stream
+	index   int
+	spool   *os.File
+	direct  bool
+	written bool
+}
+
+func (k *streamSink) Write(p []byte) (int, error) {
+	s := k.stream
+	s.mu.Lock()
+	err := s.err
+	if err == nil && k.index != 0 && !k.direct && k.index == s.next && !s.active {
+		k.direct = true
+		s.active = true
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	if k.index == 0 || k.direct {
+		if k.spool != nil {
+			// Bytes spooled before this entry reached the head go first.
+			k.written = true
+			err := s.flush(k.spool, true)
+			k.spool = nil
+			if err != nil {
+				s.fail(err)
+				return 0, err
+			}
+		}
+		n, err := s.write(p)
+		if n > 0 {
+			k.written = true
+		}
+		s.fail(err)
+		return n, err
+	}
+
+	if k.spool == nil {
+		f, err := os.CreateTemp(s.spoolDir, ".bandfetch-stream-*")
+		if err != nil {
+			return 0, err
+		}
+		k.spool = f
+	}
+	return k.spool.Write(p)
+}
+
+func (k *streamSink) Commit() error {
+	s := k.stream
+	if k.index == 0 {
+		return s.Err()
+	}
+	s.mu.Lock()
+	if k.direct {
+		s.next++
+	} else {
+		// Empty bodies leave a nil spool: nothing to flush, but the slot
+		// still counts.
+		s.ready[k.index] = k.spool
+		k.spool = nil
+	}
+	own := k.direct || s.claimLocked()
+	s.mu.Unlock()
+	if own {
+		s.drain()
+	}
+	return s.Err()
+}
+
+func (k *streamSink) Abort() error {
+	if k.spool != nil {
+		k.spool.Close()
+		os.Remove(k.spool.Name())
+		k.spool = nil
+	}
+	if k.direct {
+		s := k.stream
+		s.mu.Lock()
+		s.active = false
+		if k.written {
+			s.failLocked(ErrStreamBroken)
+		}
+		s.mu.Unlock()
+		k.direct = false
+	}
+	return nil
+}
+
+// Destination reports "-" for standard output style streams.
+func (k *streamSink) Destination() string { return "-" }
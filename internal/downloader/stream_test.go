@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/urls"
+)
+
+func TestStreamPreservesListOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n == 3 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Earlier entries finish last so ordering has to be restored.
+		time.Sleep(time.Duration(6-n) * 20 * time.Millisecond)
+		fmt.Fprintf(w, "[%d]", n)
+	}))
+	t.Cleanup(srv.Close)
+
+	var out bytes.Buffer
+	stream := NewStream(&out, t.TempDir())
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{Sink: stream})
+	mgr := NewManager(dl, 5)
+	var status bytes.Buffer
+	mgr.SetOutput(&status)
+
+	var list []string
+	for i := 1; i <= 5; i++ {
+		list = append(list, fmt.Sprintf("%s/%d", srv.URL, i))
+	}
+	if err := mgr.Run(context.Background(), list); err == nil {
+		t.Fatalf("expected entry 3 to fail")
+	}
+	if got := out.String(); got != "[1][2][4][5]" {
+		t.Fatalf("expected ordered output without the failure, got %q", got)
+	}
+	if !strings.Contains(status.String(), "[FAIL]") {
+		t.Fatalf("expected status lines on the status writer, got %q", status.String())
+	}
+}
+
+func TestStreamBrokenAfterPartialWrite(t *testing.T) {
+	var out bytes.Buffer
+	stream := NewStream(&out, t.TempDir())
+	sink, err := stream.Open(SinkRequest{Entry: urls.Entry{Index: 1}})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := sink.Write([]byte("partial")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = sink.Abort()
+	if _, err := stream.Open(SinkRequest{}); err != ErrStreamBroken {
+		t.Fatalf("expected ErrStreamBroken, got %v", err)
+	}
+}
+
+func TestStreamBrokenStopsRequests(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	t.Cleanup(srv.Close)
+
+	stream := NewStream(&bytes.Buffer{}, t.TempDir())
+	sink, _ := stream.Open(SinkRequest{Entry: urls.Entry{Index: 1}})
+	_, _ = sink.Write([]byte("partial"))
+	_ = sink.Abort()
+
+	dl := New(NewHTTPClient(5*time.Second), nil, Options{Sink: stream, Retries: 3})
+	start := time.Now()
+	_, err := dl.DownloadEntry(context.Background(), urls.Entry{Mirrors: []string{srv.URL}, Index: 2})
+	if !errors.Is(err, ErrStreamBroken) {
+		t.Fatalf("expected ErrStreamBroken, got %v", err)
+	}
+	if hits != 0 {
+		t.Fatalf("expected no requests once the stream is broken, got %d", hits)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("expected no retry backoff, took %v", elapsed)
+	}
+}
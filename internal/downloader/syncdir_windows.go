@@ -0,0 +1,9 @@
+//go:build windows
+
+package downloader
+
+// syncDir is a no-op on Windows, where directories cannot be opened for
+// syncing and NTFS journals the rename itself.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package downloader
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing breaks the latency of an attempt into phases. Phases that did not
+// happen, such as DNS and Connect on a reused connection, are zero.
+type Timing struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// TTFB runs from sending the request to the first response byte,
+	// including any of the phases above.
+	TTFB   time.Duration
+	Reused bool
+}
+
+// transferStats accumulates what DownloadEntry reports to the aggregator
+// across all attempts at one entry.
+type transferStats struct {
+	attempts int
+	bytes    int64
+	timing   Timing
+}
+
+// phaseTrace records Timing through httptrace callbacks, which may run on
+// dialer goroutines.
+type phaseTrace struct {
+	mu       sync.Mutex
+	start    time.Time
+	dnsStart time.Time
+	conStart time.Time
+	tlsStart time.Time
+	timing   Timing
+	conn     string // local and remote address of the connection used
+}
+
+// withTrace returns ctx instrumented to fill the returned trace.
+func withTrace(ctx context.Context) (context.Context, *phaseTrace) {
+	pt := &phaseTrace{start: time.Now()}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			pt.mu.Lock()
+			pt.dnsStart = time.Now()
+			pt.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			pt.mu.Lock()
+			pt.timing.DNS = time.Since(pt.dnsStart)
+			pt.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			pt.mu.Lock()
+			if pt.conStart.IsZero() {
+				pt.conStart = time.Now()
+			}
+			pt.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			pt.mu.Lock()
+			if err == nil && pt.timing.Connect == 0 {
+				pt.timing.Connect = time.Since(pt.conStart)
+			}
+			pt.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			pt.mu.Lock()
+			pt.tlsStart = time.Now()
+			pt.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			pt.mu.Lock()
+			pt.timing.TLS = time.Since(pt.tlsStart)
+			pt.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			pt.mu.Lock()
+			pt.timing.Reused = info.Reused
+			if info.Conn != nil {
+				pt.conn = info.Conn.LocalAddr().String() + "->" + info.Conn.RemoteAddr().String()
+			}
+			pt.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			pt.mu.Lock()
+			pt.timing.TTFB = time.Since(pt.start)
+			pt.mu.Unlock()
+		},
+	}), pt
+}
+
+// result returns the phases observed so far.
+func (pt *phaseTrace) result() Timing {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.timing
+}
+
+// connAddr is the local and remote address pair of the connection the
+// request went out on. The pair identifies the connection: the same local
+// port may be reused towards different servers.
+func (pt *phaseTrace) connAddr() string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.conn
+}
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/cx009/netperf/internal/urls"
+)
+
+// VerifyError reports content that does not match the expected size or
+// digest. It is treated like a server fault so another mirror is tried.
+type VerifyError struct {
+	What string
+	Want string
+	Got  string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s mismatch: want %s, got %s", e.What, e.Want, e.Got)
+}
+
+// verifier checks received content against an entry's expected metadata.
+type verifier struct {
+	size     int64
+	checksum urls.Checksum
+	hash     hash.Hash
+	n        int64
+}
+
+func newVerifier(entry urls.Entry) (*verifier, error) {
+	v := &verifier{size: entry.Size, checksum: entry.Hash}
+	switch strings.ToLower(entry.Hash.Algorithm) {
+	case "":
+	case "sha512":
+		v.hash = sha512.New()
+	case "sha256":
+		v.hash = sha256.New()
+	case "sha1":
+		v.hash = sha1.New()
+	case "md5":
+		v.hash = md5.New()
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q", entry.Hash.Algorithm)
+	}
+	return v, nil
+}
+
+// active reports whether the verifier needs to see the body.
+func (v *verifier) active() bool {
+	return v.size > 0 || v.hash != nil
+}
+
+// wrap tees writes through the verifier when there is anything to check.
+func (v *verifier) wrap(w io.Writer) io.Writer {
+	if !v.active() {
+		return w
+	}
+	return io.MultiWriter(w, v)
+}
+
+func (v *verifier) Write(p []byte) (int, error) {
+	v.n += int64(len(p))
+	if v.hash != nil {
+		v.hash.Write(p)
+	}
+	return len(p), nil
+}
+
+func (v *verifier) check() error {
+	if v.size > 0 && v.n != v.size {
+		return &VerifyError{What: "size", Want: fmt.Sprint(v.size), Got: fmt.Sprint(v.n)}
+	}
+	if v.hash != nil {
+		got := hex.EncodeToString(v.hash.Sum(nil))
+		if !strings.EqualFold(got, v.checksum.Value) {
+			return &VerifyError{What: v.checksum.Algorithm, Want: v.checksum.Value, Got: got}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+type workerKey struct{}
+
+// workerTally is what a Manager worker learns from the downloads it runs.
+type workerTally struct {
+	id    int
+	bytes atomic.Int64
+}
+
+// withWorker makes downloads under ctx add the bytes they read to w, so
+// the Manager can attribute wire bytes, including those of failed and
+// retried attempts, to the worker that moved them.
+func withWorker(ctx context.Context, w *workerTally) context.Context {
+	return context.WithValue(ctx, workerKey{}, w)
+}
+
+// workerOf returns ctx's worker, or nil outside a Manager.
+func workerOf(ctx context.Context) *workerTally {
+	w, _ := ctx.Value(workerKey{}).(*workerTally)
+	return w
+}
+
+// countWorkerBytes wraps r to count into ctx's worker, if any.
+func countWorkerBytes(ctx context.Context, r io.Reader) io.Reader {
+	w := workerOf(ctx)
+	if w == nil {
+		return r
+	}
+	return readerFunc(func(p []byte) (int, error) {
+		m, err := r.Read(p)
+		w.bytes.Add(int64(m))
+		return m, err
+	})
+}
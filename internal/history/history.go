@@ -0,0 +1,161 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cx009/netperf/internal/report"
+)
+
+// FileName is the JSON Lines file kept in the history directory.
+const FileName = "history.jsonl"
+
+// Record is one run in the history.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Host is the machine the run was made from.
+	Host    string `json:"host"`
+	Label   string `json:"label,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	List    string `json:"list"`
+	// Settings is the effective configuration (header values masked).
+	Settings map[string]string `json:"settings,omitempty"`
+	Result   report.Report     `json:"result"`
+}
+
+// NewRecord returns a record for result stamped with its time and this
+// machine's host name.
+func NewRecord(result report.Report) Record {
+	host, _ := os.Hostname()
+	t := result.Time
+	if t.IsZero() {
+		t = time.Now().UTC()
+	}
+	return Record{Time: t, Host: host, Result: result}
+}
+
+// Store is an append-only history file in a directory.
+type Store struct {
+	path string
+}
+
+// Open returns the store kept in dir. Nothing is created until Append.
+func Open(dir string) *Store {
+	return &Store{path: filepath.Join(dir, FileName)}
+}
+
+// Path returns the history file's location.
+func (s *Store) Path() string { return s.path }
+
+// Append adds r as one line.
+func (s *Store) Append(r Record) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load returns every record, oldest first, and the numbers of the lines it
+// could not decode. A line torn by an interrupted append, or otherwise
+// corrupt, is skipped rather than failing the whole history; callers should
+// warn about the skipped lines. A missing file is an empty history.
+func (s *Store) Load() ([]Record, []int, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var (
+		out     []Record
+		skipped []int
+	)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if json.Unmarshal(sc.Bytes(), &r) != nil {
+			skipped = append(skipped, n)
+			continue
+		}
+		out = append(out, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, skipped, nil
+}
+
+// SkippedWarning describes the lines Load skipped in the store's file, or
+// returns "" when there were none.
+func (s *Store) SkippedWarning(lines []int) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	nums := make([]string, len(lines))
+	for i, n := range lines {
+		nums[i] = strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%s: skipped %d unreadable line(s): %s", s.path, len(lines), strings.Join(nums, ", "))
+}
+
+// Filter selects records; empty fields match anything.
+type Filter struct {
+	Label   string
+	Profile string
+	List    string
+	Host    string
+}
+
+// Match reports whether r passes the filter.
+func (f Filter) Match(r Record) bool {
+	return (f.Label == "" || f.Label == r.Label) &&
+		(f.Profile == "" || f.Profile == r.Profile) &&
+		(f.List == "" || f.List == r.List) &&
+		(f.Host == "" || f.Host == r.Host)
+}
+
+// Select returns the records matching f, preserving order.
+func Select(records []Record, f Filter) []Record {
+	var out []Record
+	for _, r := range records {
+		if f.Match(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Recent returns the last n records, or all of them when n <= 0.
+func Recent(records []Record, n int) []Record {
+	if n <= 0 || n >= len(records) {
+		return records
+	}
+	return records[len(records)-n:]
+}
@@ -0,0 +1,98 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/report"
+)
+
+func record(list, host, label string, day int, avg float64) Record {
+	return Record{
+		Time:   time.Date(2026, 3, day, 2, 0, 0, 0, time.UTC),
+		Host:   host,
+		Label:  label,
+		List:   list,
+		Result: report.Report{Version: report.Version, AvgBps: avg},
+	}
+}
+
+func TestStoreAppendLoad(t *testing.T) {
+	store := Open(t.TempDir() + "/hist")
+	if recs, _, err := store.Load(); err != nil || recs != nil {
+		t.Fatalf("expected empty history, got %v, %v", recs, err)
+	}
+	// Appended out of order; Load returns them oldest first.
+	for _, r := range []Record{record("a.txt", "ci-1", "nightly", 2, 200), record("a.txt", "ci-1", "", 1, 100)} {
+		if err := store.Append(r); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	recs, skipped, err := store.Load()
+	if err != nil || skipped != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(recs) != 2 || recs[0].Result.AvgBps != 100 || recs[1].Label != "nightly" {
+		t.Fatalf("unexpected records: %+v", recs)
+	}
+	if got := Select(recs, Filter{Label: "nightly"}); len(got) != 1 {
+		t.Fatalf("expected one nightly run, got %d", len(got))
+	}
+
+	f, _ := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("{broken\n")
+	data, _ := json.Marshal(record("a.txt", "ci-1", "", 3, 300))
+	f.Write(data[:len(data)/2]) // torn by an interrupted append
+	f.Close()
+	recs, skipped, err = store.Load()
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("expected the good records despite bad lines, got %d, %v", len(recs), err)
+	}
+	if len(skipped) != 2 || skipped[0] != 3 || skipped[1] != 4 {
+		t.Fatalf("expected lines 3 and 4 skipped, got %v", skipped)
+	}
+	if w := store.SkippedWarning(skipped); !strings.Contains(w, "skipped 2 unreadable line(s): 3, 4") {
+		t.Fatalf("unexpected warning %q", w)
+	}
+}
+
+func TestTrends(t *testing.T) {
+	recs := []Record{
+		record("a.txt", "ci-1", "", 1, 100),
+		record("b.txt", "ci-2", "", 2, 50),
+		record("a.txt", "ci-2", "", 3, 300),
+		record("a.txt", "ci-1", "", 4, 200),
+	}
+	trends := Trends(recs, ByList, 2)
+	if len(trends) != 2 || trends[0].Key != "a.txt" {
+		t.Fatalf("unexpected trends: %+v", trends)
+	}
+	a := trends[0]
+	if a.Runs != 3 || a.Latest != 200 || a.MovingAvg != 250 || a.Min != 100 || a.Max != 300 {
+		t.Fatalf("unexpected trend for a.txt: %+v", a)
+	}
+	if math.Abs(a.Change-(-1.0/3)) > 1e-9 {
+		t.Fatalf("expected -33%% change, got %v", a.Change)
+	}
+	if !math.IsNaN(trends[1].Change) {
+		t.Fatalf("single run should have no change, got %v", trends[1].Change)
+	}
+
+	byHost := Trends(recs, ByHost, 5)
+	if len(byHost) != 2 || byHost[1].Key != "ci-2" || byHost[1].Runs != 2 {
+		t.Fatalf("unexpected host trends: %+v", byHost)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTrends(&buf, trends, ByList, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "AVG(2)") || !strings.Contains(buf.String(), "-33.3%") {
+		t.Fatalf("unexpected trend table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,136 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// GroupBy chooses what trends are computed over.
+type GroupBy string
+
+const (
+	ByList GroupBy = "list"
+	ByHost GroupBy = "host"
+)
+
+// ParseGroupBy validates a -by value.
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch g := GroupBy(s); g {
+	case ByList, ByHost:
+		return g, nil
+	case "":
+		return ByList, nil
+	}
+	return "", fmt.Errorf("unknown grouping %q (want list or host)", s)
+}
+
+// DefaultWindow is the moving average length in runs.
+const DefaultWindow = 5
+
+// Trend summarizes the average throughput of one group's runs.
+type Trend struct {
+	Key  string
+	Runs int
+	// Latest is the most recent run's average bit/s; MovingAvg averages
+	// the last window runs.
+	Latest    float64
+	MovingAvg float64
+	Min       float64
+	Max       float64
+	// Change is the latest run's relative change over the previous one,
+	// NaN with a single run.
+	Change float64
+}
+
+// Trends groups records (oldest first) and computes each group's trend,
+// sorted by key.
+func Trends(records []Record, by GroupBy, window int) []Trend {
+	if window < 1 {
+		window = DefaultWindow
+	}
+	groups := map[string][]float64{}
+	for _, r := range records {
+		key := r.List
+		if by == ByHost {
+			key = r.Host
+		}
+		groups[key] = append(groups[key], r.Result.AvgBps)
+	}
+
+	out := make([]Trend, 0, len(groups))
+	for key, vals := range groups {
+		t := Trend{Key: key, Runs: len(vals), Latest: vals[len(vals)-1], Min: vals[0], Max: vals[0], Change: math.NaN()}
+		for _, v := range vals {
+			t.Min = min(t.Min, v)
+			t.Max = max(t.Max, v)
+		}
+		recent := vals[max(0, len(vals)-window):]
+		for _, v := range recent {
+			t.MovingAvg += v
+		}
+		t.MovingAvg /= float64(len(recent))
+		if n := len(vals); n > 1 && vals[n-2] > 0 {
+			t.Change = (vals[n-1] - vals[n-2]) / vals[n-2]
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// WriteList prints one line per run.
+func WriteList(w io.Writer, records []Record) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tHOST\tLABEL\tPROFILE\tLIST\tAVERAGE\tPEAK\tERRORS")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.1f%%\n",
+			r.Time.Local().Format("2006-01-02 15:04"),
+			orDash(r.Host), orDash(r.Label), orDash(r.Profile), orDash(r.List),
+			metrics.HumanBitsPerSecond(r.Result.AvgBps),
+			metrics.HumanBitsPerSecond(r.Result.PeakBps),
+			r.Result.ErrorRate*100)
+	}
+	return tw.Flush()
+}
+
+// WriteTrends prints one line per group.
+func WriteTrends(w io.Writer, trends []Trend, by GroupBy, window int) error {
+	if window < 1 {
+		window = DefaultWindow
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tRUNS\tLATEST\tAVG(%d)\tMIN\tMAX\tCHANGE\n", headerFor(by), window)
+	for _, t := range trends {
+		change := "-"
+		if !math.IsNaN(t.Change) {
+			change = fmt.Sprintf("%+.1f%%", t.Change*100)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			orDash(t.Key), t.Runs,
+			metrics.HumanBitsPerSecond(t.Latest),
+			metrics.HumanBitsPerSecond(t.MovingAvg),
+			metrics.HumanBitsPerSecond(t.Min),
+			metrics.HumanBitsPerSecond(t.Max),
+			change)
+	}
+	return tw.Flush()
+}
+
+func headerFor(by GroupBy) string {
+	if by == ByHost {
+		return "HOST"
+	}
+	return "LIST"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
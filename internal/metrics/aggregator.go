@@ -3,16 +3,61 @@ package metrics
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// FileOutcome classifies how a single download finished.
+type FileOutcome int
+
+const (
+	FileDownloaded FileOutcome = iota
+	FileUnchanged              // revalidated with a 304, not rewritten
+	FileSkipped                // destination existed and was left alone
+	FileFailed
+	numFileOutcomes
+)
+
 // Aggregator tracks download byte counts for bandwidth calculations.
 type Aggregator struct {
 	bytesThisSec atomic.Int64
 	bytesTotal   atomic.Int64
+	goodBytes    atomic.Int64
 	peakBps      atomic.Uint64 // stored as uint64 bits representation of float64
+	files        [numFileOutcomes]atomic.Int64
+	readNanos    atomic.Int64
+	writeNanos   atomic.Int64
 	start        time.Time
+
+	mu         sync.Mutex
+	saturation Saturation
+	aborted    string
+	samples    []float64 // bit/s per sampling interval, see AddSample
+	transfers  []Transfer
+	workers    map[int]*WorkerStat
+
+	// failures and droppedTransfers keep the failure breakdown exact
+	// once transfers is full.
+	failures         map[failureKey]int
+	droppedTransfers int
+
+	// connWorker is the first worker seen on each HTTP/2 connection;
+	// sharedConns those a second worker used too.
+	connWorker  map[string]int
+	sharedConns map[string]bool
+}
+
+// Saturation is the outcome of adaptive worker scaling: the concurrency
+// level beyond which more workers stopped improving throughput.
+type Saturation struct {
+	Workers int
+	Bps     float64
+	// Saturated is false when the run ended, or the worker ceiling was
+	// reached, while throughput was still improving.
+	Saturated bool
 }
 
 // NewAggregator constructs an Aggregator with current start time.
@@ -29,6 +74,126 @@ func (a *Aggregator) AddBytes(n int) {
 	a.bytesTotal.Add(int64(n))
 }
 
+// AddGoodput counts the n body bytes of a download that succeeded.
+// AddBytes counts everything read off the wire, including failed and
+// retried attempts; the difference between the two is wasted transfer.
+func (a *Aggregator) AddGoodput(n int64) {
+	if n > 0 {
+		a.goodBytes.Add(n)
+	}
+}
+
+// GoodputBytes returns the bytes recorded by AddGoodput.
+func (a *Aggregator) GoodputBytes() int64 {
+	return a.goodBytes.Load()
+}
+
+// RecordFile counts one finished download under the given outcome.
+func (a *Aggregator) RecordFile(o FileOutcome) {
+	if o < 0 || o >= numFileOutcomes {
+		return
+	}
+	a.files[o].Add(1)
+}
+
+// Files returns how many downloads finished with the given outcome.
+func (a *Aggregator) Files(o FileOutcome) int64 {
+	if o < 0 || o >= numFileOutcomes {
+		return 0
+	}
+	return a.files[o].Load()
+}
+
+// AddReadTime records time workers spent blocked reading from the network.
+func (a *Aggregator) AddReadTime(d time.Duration) {
+	if d > 0 {
+		a.readNanos.Add(int64(d))
+	}
+}
+
+// AddWriteTime records time workers spent blocked writing to sinks.
+func (a *Aggregator) AddWriteTime(d time.Duration) {
+	if d > 0 {
+		a.writeNanos.Add(int64(d))
+	}
+}
+
+// ReadTime returns the cumulative network read time across workers.
+func (a *Aggregator) ReadTime() time.Duration {
+	return time.Duration(a.readNanos.Load())
+}
+
+// WriteTime returns the cumulative sink write time across workers.
+func (a *Aggregator) WriteTime() time.Duration {
+	return time.Duration(a.writeNanos.Load())
+}
+
+// AddSample records one throughput observation (bit/s over a sampling
+// interval) for percentile reporting and updates the peak.
+func (a *Aggregator) AddSample(bps float64) {
+	if bps < 0 || math.IsNaN(bps) || math.IsInf(bps, 0) {
+		return
+	}
+	a.UpdatePeakBps(bps)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.samples = append(a.samples, bps)
+}
+
+// Samples returns a copy of the recorded throughput samples.
+func (a *Aggregator) Samples() []float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]float64(nil), a.samples...)
+}
+
+// Percentile returns the nearest-rank p-th percentile (0-100) of values,
+// or 0 for an empty slice.
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// SetSaturation records the result of adaptive worker scaling.
+func (a *Aggregator) SetSaturation(s Saturation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.saturation = s
+}
+
+// Saturation returns what SetSaturation recorded; Workers is 0 if
+// autoscaling was not used.
+func (a *Aggregator) Saturation() Saturation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.saturation
+}
+
+// SetAborted records why the run was cut short by a failure budget.
+func (a *Aggregator) SetAborted(reason string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.aborted = reason
+}
+
+// Aborted returns what SetAborted recorded, or "" if the run completed.
+func (a *Aggregator) Aborted() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.aborted
+}
+
 // SwapBytesThisSecond atomically swaps the per-second counter with zero and returns the previous value.
 func (a *Aggregator) SwapBytesThisSecond() int64 {
 	return a.bytesThisSec.Swap(0)
@@ -88,12 +253,15 @@ func HumanBytes(b float64) string {
 
 // AverageBps computes the average bit/s throughput.
 func (a *Aggregator) AverageBps() float64 {
-	elapsed := a.Elapsed().Seconds()
+	return rate(a.TotalBytes(), a.Elapsed())
+}
+
+// rate is n bytes over elapsed in bit/s.
+func rate(n int64, elapsed time.Duration) float64 {
 	if elapsed <= 0 {
 		return 0
 	}
-	totalBits := float64(a.TotalBytes()) * 8
-	return totalBits / elapsed
+	return float64(n) * 8 / elapsed.Seconds()
 }
 
 // UpdatePeakBps updates the peak bandwidth if the current value is higher.
@@ -151,6 +319,8 @@ func (e *EWMA) Update(observation float64) float64 {
 }
 
 // Summary contains aggregated statistics for a download session.
+// TotalBytes and the speeds are throughput: every byte on the wire,
+// including failed and retried attempts.
 type Summary struct {
 	TotalBytes   int64
 	Elapsed      time.Duration
@@ -160,6 +330,40 @@ type Summary struct {
 	ElapsedStr   string
 	AvgBpsStr    string
 	PeakBpsStr   string
+
+	// P95Bps is the 95th percentile of the recorded throughput samples,
+	// 0 when no sampler ran.
+	P95Bps float64
+
+	// GoodputBytes counts the bodies of successful downloads only, and
+	// GoodputBps is their average rate over the whole run.
+	GoodputBytes int64
+	GoodputBps   float64
+
+	FilesDownloaded int64
+	FilesUnchanged  int64
+	FilesSkipped    int64
+	FilesFailed     int64
+
+	// NetworkReadTime and DiskWriteTime are summed over all workers; their
+	// ratio shows whether the network or the sink is the bottleneck.
+	NetworkReadTime time.Duration
+	DiskWriteTime   time.Duration
+
+	// Saturation is set when the worker pool was autoscaled.
+	Saturation Saturation
+	// Aborted says why a failure budget stopped the run early, if it did.
+	Aborted string
+	// Failures breaks failed downloads down by error class and host.
+	Failures []FailureStat
+	// TransfersDropped counts transfers left out of per-URL reports after
+	// MaxTransfers; they are still in the totals above.
+	TransfersDropped int
+
+	// Workers and Fairness show how throughput was split between the
+	// Manager's workers; both are empty without a Manager.
+	Workers  []WorkerStat
+	Fairness Fairness
 }
 
 // GetSummary returns a formatted summary of the download statistics.
@@ -168,6 +372,10 @@ func (a *Aggregator) GetSummary() Summary {
 	elapsed := a.Elapsed()
 	avgBps := a.AverageBps()
 	peakBps := a.PeakBps()
+	goodBytes := a.GoodputBytes()
+	workers := a.Workers()
+	fairness := FairnessOf(workers)
+	fairness.SharedConns = a.SharedConns()
 
 	return Summary{
 		TotalBytes:   totalBytes,
@@ -178,25 +386,95 @@ func (a *Aggregator) GetSummary() Summary {
 		ElapsedStr:   formatDuration(elapsed),
 		AvgBpsStr:    HumanBitsPerSecond(avgBps),
 		PeakBpsStr:   HumanBitsPerSecond(peakBps),
+		P95Bps:       Percentile(a.Samples(), 95),
+		GoodputBytes: goodBytes,
+		GoodputBps:   rate(goodBytes, elapsed),
+
+		FilesDownloaded: a.Files(FileDownloaded),
+		FilesUnchanged:  a.Files(FileUnchanged),
+		FilesSkipped:    a.Files(FileSkipped),
+		FilesFailed:     a.Files(FileFailed),
+
+		NetworkReadTime: a.ReadTime(),
+		DiskWriteTime:   a.WriteTime(),
+
+		Saturation: a.Saturation(),
+		Aborted:    a.Aborted(),
+		Failures:   a.Failures(),
+
+		TransfersDropped: a.DroppedTransfers(),
+		Workers:          workers,
+		Fairness:         fairness,
 	}
 }
 
+// Rows returns the labelled values shown in the summary box, for reports
+// in other formats.
+func (s Summary) Rows() [][2]string {
+	rows := [][2]string{
+		{"Total Downloaded", s.TotalSizeStr},
+		{"Elapsed Time", s.ElapsedStr},
+		{"Average Speed", s.AvgBpsStr},
+		{"Peak Speed", s.PeakBpsStr},
+	}
+	if s.P95Bps > 0 {
+		rows = append(rows, [2]string{"P95 Speed", HumanBitsPerSecond(s.P95Bps)})
+	}
+	rows = append(rows, [2]string{"Goodput", fmt.Sprintf("%s (%s)", HumanBytes(float64(s.GoodputBytes)), HumanBitsPerSecond(s.GoodputBps))})
+	rows = append(rows, [][2]string{
+		{"Files Downloaded", fmt.Sprint(s.FilesDownloaded)},
+		{"Files Unchanged", fmt.Sprint(s.FilesUnchanged)},
+		{"Files Skipped", fmt.Sprint(s.FilesSkipped)},
+		{"Files Failed", fmt.Sprint(s.FilesFailed)},
+		{"Network Read", formatShare(s.NetworkReadTime, s.DiskWriteTime)},
+		{"Disk Write", formatShare(s.DiskWriteTime, s.NetworkReadTime)},
+	}...)
+	rows = append(rows, s.Fairness.rows()...)
+	if sat := s.Saturation; sat.Workers > 0 {
+		label := "Saturated At"
+		if !sat.Saturated {
+			label = "Best Workers"
+		}
+		rows = append(rows, [2]string{label, fmt.Sprintf("%d workers (%s)", sat.Workers, HumanBitsPerSecond(sat.Bps))})
+	}
+	if s.Aborted != "" {
+		rows = append(rows, [2]string{"Aborted", s.Aborted})
+	}
+	if s.TransfersDropped > 0 {
+		rows = append(rows, [2]string{"Not In Reports", fmt.Sprintf("%d transfers", s.TransfersDropped)})
+	}
+	return rows
+}
+
 // FormatSummary returns a multi-line formatted summary report.
 func (s Summary) FormatSummary() string {
-	return fmt.Sprintf(`
+	var b strings.Builder
+	b.WriteString(`
 ╔══════════════════════════════════════════════════════╗
 ║              Download Summary Report                 ║
 ╠══════════════════════════════════════════════════════╣
-║  Total Downloaded : %-31s  ║
-║  Elapsed Time     : %-31s  ║
-║  Average Speed    : %-31s  ║
-║  Peak Speed       : %-31s  ║
-╚══════════════════════════════════════════════════════╝`,
-		s.TotalSizeStr,
-		s.ElapsedStr,
-		s.AvgBpsStr,
-		s.PeakBpsStr,
-	)
+`)
+	for _, r := range s.Rows() {
+		fmt.Fprintf(&b, "║  %-16s : %-31s  ║\n", r[0], r[1])
+	}
+	if rows := s.FailureRows(); len(rows) > 0 {
+		b.WriteString("╠══════════════════════════════════════════════════════╣\n")
+		fmt.Fprintf(&b, "║  %-16s : %-31s  ║\n", "Failure", "Count Host")
+		for _, r := range rows {
+			fmt.Fprintf(&b, "║  %-16s : %-31s  ║\n", r[0], r[1])
+		}
+	}
+	b.WriteString("╚══════════════════════════════════════════════════════╝")
+	return b.String()
+}
+
+// formatShare renders d with its share of d+other, e.g. "12.3s (87%)".
+func formatShare(d, other time.Duration) string {
+	total := d + other
+	if total <= 0 {
+		return formatDuration(d)
+	}
+	return fmt.Sprintf("%s (%.0f%%)", formatDuration(d), float64(d)/float64(total)*100)
 }
 
 // formatDuration formats a duration in a human-readable format.
@@ -183,3 +183,39 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestRecordFile(t *testing.T) {
+	agg := NewAggregator()
+	agg.RecordFile(FileDownloaded)
+	agg.RecordFile(FileDownloaded)
+	agg.RecordFile(FileUnchanged)
+	agg.RecordFile(FileOutcome(99))
+
+	s := agg.GetSummary()
+	if s.FilesDownloaded != 2 || s.FilesUnchanged != 1 || s.FilesSkipped != 0 {
+		t.Fatalf("unexpected file counts: %+v", s)
+	}
+	if !contains(s.FormatSummary(), "Files Unchanged") {
+		t.Fatalf("expected file counts in summary")
+	}
+}
+
+func TestPercentileAndSamples(t *testing.T) {
+	if got := Percentile(nil, 95); got != 0 {
+		t.Fatalf("expected 0 for no samples, got %v", got)
+	}
+	agg := NewAggregator()
+	for i := 1; i <= 20; i++ {
+		agg.AddSample(float64(i * 100))
+	}
+	s := agg.GetSummary()
+	if s.P95Bps != 1900 {
+		t.Fatalf("expected p95 of 1900, got %v", s.P95Bps)
+	}
+	if s.PeakBps != 2000 {
+		t.Fatalf("expected samples to update the peak, got %v", s.PeakBps)
+	}
+	if !contains(s.FormatSummary(), "P95 Speed") {
+		t.Fatalf("expected p95 row in summary")
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ErrorClass is the category of a failed download, see
+// downloader.Classify.
+type ErrorClass string
+
+const (
+	ClassDNS            ErrorClass = "dns"
+	ClassConnectRefused ErrorClass = "connect refused"
+	ClassConnectTimeout ErrorClass = "connect timeout"
+	ClassTLS            ErrorClass = "tls"
+	ClassHTTP4xx        ErrorClass = "http 4xx"
+	ClassHTTP5xx        ErrorClass = "http 5xx"
+	ClassTimeout        ErrorClass = "timeout"      // e.g. awaiting headers
+	ClassBodyTimeout    ErrorClass = "body timeout" // reading the body
+	ClassStall          ErrorClass = "stall"        // idle or below the low-speed limit
+	ClassReset          ErrorClass = "reset"
+	ClassShortRead      ErrorClass = "short read"
+	ClassVerify         ErrorClass = "verify"
+	ClassDisk           ErrorClass = "disk"
+	ClassCancelled      ErrorClass = "cancelled"
+	ClassOther          ErrorClass = "other"
+)
+
+// FailureStat counts the failed downloads of one class from one host.
+type FailureStat struct {
+	Class ErrorClass
+	Host  string
+	Count int
+}
+
+// failureKey groups failed transfers for the breakdown.
+type failureKey struct {
+	class ErrorClass
+	host  string
+}
+
+func keyOf(t Transfer) failureKey {
+	class := t.Class
+	if class == "" {
+		class = ClassOther
+	}
+	return failureKey{class, t.Host}
+}
+
+// FailureBreakdown counts failed transfers by class and host, most
+// frequent first.
+func FailureBreakdown(transfers []Transfer) []FailureStat {
+	counts := map[failureKey]int{}
+	for _, t := range transfers {
+		if t.Outcome == FileFailed {
+			counts[keyOf(t)]++
+		}
+	}
+	return sortFailures(counts)
+}
+
+// Failures is the breakdown of every failed transfer recorded, including
+// those beyond MaxTransfers.
+func (a *Aggregator) Failures() []FailureStat {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sortFailures(a.failures)
+}
+
+func sortFailures(counts map[failureKey]int) []FailureStat {
+	out := make([]FailureStat, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, FailureStat{Class: k.class, Host: k.host, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.Host < b.Host
+	})
+	return out
+}
+
+// FailureRows renders the breakdown for the summary box: the class as
+// the label and the count and host as the value.
+func (s Summary) FailureRows() [][2]string {
+	rows := make([][2]string, 0, len(s.Failures))
+	for _, f := range s.Failures {
+		host := f.Host
+		if host == "" {
+			host = "-"
+		}
+		rows = append(rows, [2]string{string(f.Class), truncate(fmt.Sprintf("%-5d %s", f.Count, host), 31)})
+	}
+	return rows
+}
+
+// truncate shortens s to at most n runes, marking the cut with "...".
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
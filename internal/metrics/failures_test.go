@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFailureBreakdown(t *testing.T) {
+	agg := NewAggregator()
+	for _, tr := range []Transfer{
+		{Host: "a.example", Outcome: FileFailed, Class: ClassDNS},
+		{Host: "b.example", Outcome: FileFailed, Class: ClassHTTP5xx},
+		{Host: "a.example", Outcome: FileFailed, Class: ClassDNS},
+		{Host: "a.example", Outcome: FileDownloaded},
+		{Host: "c.example", Outcome: FileFailed},
+	} {
+		agg.RecordTransfer(tr)
+	}
+
+	s := agg.GetSummary()
+	want := []FailureStat{
+		{Class: ClassDNS, Host: "a.example", Count: 2},
+		{Class: ClassHTTP5xx, Host: "b.example", Count: 1},
+		{Class: ClassOther, Host: "c.example", Count: 1},
+	}
+	if len(s.Failures) != len(want) {
+		t.Fatalf("unexpected breakdown: %+v", s.Failures)
+	}
+	for i := range want {
+		if s.Failures[i] != want[i] {
+			t.Fatalf("row %d: got %+v, want %+v", i, s.Failures[i], want[i])
+		}
+	}
+	if !strings.Contains(s.FormatSummary(), "║  dns              : 2     a.example                  ║") {
+		t.Fatalf("expected the breakdown in the summary box:\n%s", s.FormatSummary())
+	}
+}
+
+func TestRecordTransferCapsKeptTransfers(t *testing.T) {
+	agg := NewAggregator()
+	for i := 0; i < MaxTransfers+5; i++ {
+		agg.RecordTransfer(Transfer{Host: "a.example", Outcome: FileFailed, Class: ClassReset})
+	}
+	if n := len(agg.Transfers()); n != MaxTransfers {
+		t.Fatalf("expected %d kept transfers, got %d", MaxTransfers, n)
+	}
+	s := agg.GetSummary()
+	if s.TransfersDropped != 5 {
+		t.Fatalf("expected 5 dropped transfers, got %d", s.TransfersDropped)
+	}
+	if len(s.Failures) != 1 || s.Failures[0].Count != MaxTransfers+5 {
+		t.Fatalf("expected every failure counted, got %+v", s.Failures)
+	}
+}
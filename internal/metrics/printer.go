@@ -3,12 +3,19 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"time"
 )
 
 // StartPrinter launches a goroutine that periodically prints bandwidth metrics.
 func StartPrinter(ctx context.Context, agg *Aggregator, enabled bool, wg *sync.WaitGroup) {
+	StartPrinterTo(ctx, os.Stdout, agg, enabled, wg)
+}
+
+// StartPrinterTo is StartPrinter writing to w instead of stdout.
+func StartPrinterTo(ctx context.Context, w io.Writer, agg *Aggregator, enabled bool, wg *sync.WaitGroup) {
 	if !enabled {
 		return
 	}
@@ -33,10 +40,10 @@ func StartPrinter(ctx context.Context, agg *Aggregator, enabled bool, wg *sync.W
 				avg := agg.AverageBps()
 				total := agg.TotalBytes()
 
-				// Track peak bandwidth
-				agg.UpdatePeakBps(bps)
+				// Track peak bandwidth and keep the tick for percentiles
+				agg.AddSample(bps)
 
-				fmt.Printf("[BW] now=%s  ewma=%s  avg=%s  total=%s\n",
+				fmt.Fprintf(w, "[BW] now=%s  ewma=%s  avg=%s  total=%s\n",
 					HumanBitsPerSecond(bps),
 					HumanBitsPerSecond(smoothed),
 					HumanBitsPerSecond(avg),
@@ -46,3 +53,32 @@ func StartPrinter(ctx context.Context, agg *Aggregator, enabled bool, wg *sync.W
 		}
 	}()
 }
+
+// StartSampler launches a goroutine that records a throughput sample
+// every interval (see Aggregator.AddSample) until ctx is done. The printer
+// already records its per-second ticks, so this is for runs without it.
+func StartSampler(ctx context.Context, agg *Aggregator, interval time.Duration, wg *sync.WaitGroup) {
+	if agg == nil || interval <= 0 {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last, lastAt := agg.TotalBytes(), time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				total := agg.TotalBytes()
+				if secs := now.Sub(lastAt).Seconds(); secs > 0 {
+					agg.AddSample(float64(total-last) * 8 / secs)
+				}
+				last, lastAt = total, now
+			}
+		}
+	}()
+}
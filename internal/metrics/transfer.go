@@ -0,0 +1,71 @@
+package metrics
+
+import "time"
+
+// MaxTransfers is how many transfers an Aggregator keeps for per-URL
+// reports. Later ones still count toward the summary and the failure
+// breakdown, so long soak runs do not grow without bound.
+const MaxTransfers = 100_000
+
+// Transfer describes one finished download for per-URL and per-host
+// reporting. Phase timings are those of the last attempt.
+type Transfer struct {
+	URL  string
+	Host string
+	// Mirror is the URL that served the object, if it succeeded.
+	Mirror  string
+	Outcome FileOutcome
+	Err     string
+	// Class categorizes Err for failed transfers.
+	Class    ErrorClass
+	Start    time.Time
+	Duration time.Duration
+	Attempts int
+	Bytes    int64
+
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+}
+
+// Bps is the transfer's throughput over its whole duration in bit/s.
+func (t Transfer) Bps() float64 {
+	if t.Duration <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) * 8 / t.Duration.Seconds()
+}
+
+// RecordTransfer keeps t for reports, up to MaxTransfers, and counts it
+// in the failure breakdown if it failed.
+func (a *Aggregator) RecordTransfer(t Transfer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if t.Outcome == FileFailed {
+		if a.failures == nil {
+			a.failures = map[failureKey]int{}
+		}
+		a.failures[keyOf(t)]++
+	}
+	if len(a.transfers) >= MaxTransfers {
+		a.droppedTransfers++
+		return
+	}
+	a.transfers = append(a.transfers, t)
+}
+
+// DroppedTransfers is how many transfers were not kept because
+// MaxTransfers was reached.
+func (a *Aggregator) DroppedTransfers() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.droppedTransfers
+}
+
+// Transfers returns the kept transfers in completion order.
+func (a *Aggregator) Transfers() []Transfer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Transfer(nil), a.transfers...)
+}
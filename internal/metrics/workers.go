@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// WorkerStat accumulates what one download worker did. Each worker runs
+// one transfer at a time, so over HTTP/1.1 it stands for one flow when
+// judging whether the network treats concurrent connections fairly. Over
+// HTTP/2 several workers share a connection, see Fairness.SharedConns.
+type WorkerStat struct {
+	ID    int
+	Files int
+	// Bytes counts everything the worker read off the wire.
+	Bytes int64
+	// Active is time spent downloading, Idle time spent waiting for the
+	// next job.
+	Active time.Duration
+	Idle   time.Duration
+}
+
+// Bps is the worker's throughput while active in bit/s.
+func (w WorkerStat) Bps() float64 {
+	return rate(w.Bytes, w.Active)
+}
+
+// RecordWorkerJob adds one finished job to worker id's totals.
+func (a *Aggregator) RecordWorkerJob(id int, bytes int64, active, idle time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.workers == nil {
+		a.workers = map[int]*WorkerStat{}
+	}
+	w := a.workers[id]
+	if w == nil {
+		w = &WorkerStat{ID: id}
+		a.workers[id] = w
+	}
+	w.Files++
+	w.Bytes += bytes
+	w.Active += active
+	w.Idle += idle
+}
+
+// RecordConnUse notes that worker id ran a transfer over the multiplexed
+// (HTTP/2) connection conn, keyed by its local and remote address.
+func (a *Aggregator) RecordConnUse(conn string, id int) {
+	if conn == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.connWorker == nil {
+		a.connWorker = map[string]int{}
+		a.sharedConns = map[string]bool{}
+	}
+	if first, ok := a.connWorker[conn]; !ok {
+		a.connWorker[conn] = id
+	} else if first != id {
+		a.sharedConns[conn] = true
+	}
+}
+
+// SharedConns is how many connections carried transfers of more than one
+// worker.
+func (a *Aggregator) SharedConns() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.sharedConns)
+}
+
+// Workers returns the per-worker totals ordered by worker ID.
+func (a *Aggregator) Workers() []WorkerStat {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]WorkerStat, 0, len(a.workers))
+	for _, w := range a.workers {
+		out = append(out, *w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Fairness summarizes how evenly throughput was shared between workers.
+type Fairness struct {
+	// Workers is how many workers were active; the other fields are zero
+	// when it is 0.
+	Workers int
+	// Jain is Jain's fairness index of the per-worker throughputs: 1 when
+	// all are equal, down to 1/Workers when one worker gets everything.
+	Jain      float64
+	MinBps    float64
+	MedianBps float64
+	MaxBps    float64
+	// SharedConns counts connections multiplexed between workers. When it
+	// is not 0, workers were streams on shared flows, so the index says
+	// how the client split a connection rather than how the network
+	// treated separate ones.
+	SharedConns int
+}
+
+// FairnessOf computes the fairness of the workers that did any work.
+func FairnessOf(workers []WorkerStat) Fairness {
+	var rates []float64
+	var sum, squares float64
+	for _, w := range workers {
+		if w.Active <= 0 {
+			continue
+		}
+		r := w.Bps()
+		rates = append(rates, r)
+		sum += r
+		squares += r * r
+	}
+	f := Fairness{Workers: len(rates)}
+	if f.Workers == 0 {
+		return f
+	}
+	f.Jain = 1
+	if squares > 0 {
+		f.Jain = sum * sum / (float64(f.Workers) * squares)
+	}
+	f.MinBps = Percentile(rates, 0)
+	f.MedianBps = Percentile(rates, 50)
+	f.MaxBps = Percentile(rates, 100)
+	return f
+}
+
+// rows renders the fairness for the summary box, only when there was
+// more than one worker to compare.
+func (f Fairness) rows() [][2]string {
+	if f.Workers < 2 {
+		return nil
+	}
+	rows := [][2]string{
+		{"Worker Fairness", fmt.Sprintf("%.3f Jain (%d workers)", f.Jain, f.Workers)},
+		{"Worker Min", HumanBitsPerSecond(f.MinBps)},
+		{"Worker Median", HumanBitsPerSecond(f.MedianBps)},
+		{"Worker Max", HumanBitsPerSecond(f.MaxBps)},
+	}
+	if f.SharedConns > 0 {
+		rows = append(rows, [2]string{"Shared Conns", fmt.Sprintf("%d (HTTP/2 multiplexed)", f.SharedConns)})
+	}
+	return rows
+}
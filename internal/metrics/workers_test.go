@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFairnessOf(t *testing.T) {
+	even := []WorkerStat{
+		{ID: 1, Bytes: 1000, Active: time.Second},
+		{ID: 2, Bytes: 2000, Active: 2 * time.Second},
+	}
+	if f := FairnessOf(even); f.Workers != 2 || f.Jain != 1 || f.MinBps != 8000 || f.MaxBps != 8000 {
+		t.Fatalf("expected perfectly fair workers, got %+v", f)
+	}
+
+	// Rates 1, 1, 1 and 9: Jain = 12^2 / (4 * 84).
+	skewed := []WorkerStat{
+		{ID: 1, Bytes: 1, Active: time.Second},
+		{ID: 2, Bytes: 1, Active: time.Second},
+		{ID: 3, Bytes: 1, Active: time.Second},
+		{ID: 4, Bytes: 9, Active: time.Second},
+		{ID: 5, Idle: time.Second}, // never ran a job
+	}
+	f := FairnessOf(skewed)
+	if f.Workers != 4 || math.Abs(f.Jain-144.0/336) > 1e-9 {
+		t.Fatalf("unexpected fairness: %+v", f)
+	}
+	if f.MinBps != 8 || f.MedianBps != 8 || f.MaxBps != 72 {
+		t.Fatalf("unexpected distribution: %+v", f)
+	}
+
+	if f := FairnessOf(nil); f != (Fairness{}) {
+		t.Fatalf("expected zero fairness without workers, got %+v", f)
+	}
+}
+
+func TestWorkerStatsInSummary(t *testing.T) {
+	agg := NewAggregator()
+	agg.RecordWorkerJob(2, 4000, time.Second, 0)
+	agg.RecordWorkerJob(1, 1000, time.Second, 100*time.Millisecond)
+	agg.RecordWorkerJob(1, 1000, time.Second, 300*time.Millisecond)
+
+	s := agg.GetSummary()
+	if len(s.Workers) != 2 || s.Workers[0].ID != 1 {
+		t.Fatalf("expected workers ordered by ID, got %+v", s.Workers)
+	}
+	w := s.Workers[0]
+	if w.Files != 2 || w.Bytes != 2000 || w.Active != 2*time.Second || w.Idle != 400*time.Millisecond {
+		t.Fatalf("unexpected worker totals: %+v", w)
+	}
+	box := s.FormatSummary()
+	for _, want := range []string{"Worker Fairness  : 0.735 Jain (2 workers)", "Worker Min       : 8.00 Kbit/s", "Worker Max       : 32.00 Kbit/s"} {
+		if !strings.Contains(box, want) {
+			t.Fatalf("expected %q in the summary:\n%s", want, box)
+		}
+	}
+}
+
+func TestSharedConnsInSummary(t *testing.T) {
+	agg := NewAggregator()
+	agg.RecordWorkerJob(1, 1000, time.Second, 0)
+	agg.RecordWorkerJob(2, 1000, time.Second, 0)
+	agg.RecordConnUse("10.0.0.1:5000->10.0.0.9:443", 1)
+	agg.RecordConnUse("10.0.0.1:5000->10.0.0.9:443", 1)
+	agg.RecordConnUse("10.0.0.1:5001->10.0.0.9:443", 2)
+	// The same local port towards another server is another connection.
+	agg.RecordConnUse("10.0.0.1:5000->10.0.0.8:443", 2)
+	if n := agg.GetSummary().Fairness.SharedConns; n != 0 {
+		t.Fatalf("expected no shared connections yet, got %d", n)
+	}
+
+	agg.RecordConnUse("10.0.0.1:5000->10.0.0.9:443", 2)
+	s := agg.GetSummary()
+	if s.Fairness.SharedConns != 1 {
+		t.Fatalf("expected one shared connection, got %d", s.Fairness.SharedConns)
+	}
+	if !strings.Contains(s.FormatSummary(), "Shared Conns     : 1 (HTTP/2 multiplexed)") {
+		t.Fatalf("expected the shared connections in the summary box:\n%s", s.FormatSummary())
+	}
+}
@@ -0,0 +1,153 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// Process exit statuses of a run, from least to most severe. ExitRegression
+// sits between download failures and threshold violations.
+const (
+	ExitFailed      = 1
+	ExitThreshold   = 4
+	ExitInterrupted = 130
+)
+
+// Status is what a finished run's exit status is derived from.
+type Status struct {
+	Interrupted bool
+	// Failed is set when any download failed.
+	Failed    bool
+	Regressed bool
+	Violated  bool
+}
+
+// ExitCode returns the exit status for s. Interruption wins over threshold
+// violations, which win over regressions and then failed downloads.
+func (s Status) ExitCode() int {
+	switch {
+	case s.Interrupted:
+		return ExitInterrupted
+	case s.Violated:
+		return ExitThreshold
+	case s.Regressed:
+		return ExitRegression
+	case s.Failed:
+		return ExitFailed
+	}
+	return 0
+}
+
+// Thresholds are service-level assertions on a finished run. Zero bit rates
+// and durations are not checked; MaxErrorRate is checked when non-nil so
+// that 0 can demand a clean run.
+type Thresholds struct {
+	MinAvgBps    float64
+	MinP10Bps    float64
+	MaxErrorRate *float64
+	MaxTTFBP95   time.Duration
+}
+
+// Active reports whether any threshold is set.
+func (t Thresholds) Active() bool {
+	return t.MinAvgBps > 0 || t.MinP10Bps > 0 || t.MaxErrorRate != nil || t.MaxTTFBP95 > 0
+}
+
+// Assertion is the outcome of one threshold. Limit and Actual are
+// preformatted for display.
+type Assertion struct {
+	Name   string
+	Limit  string
+	Actual string
+	// Skipped is set when the run has no data for the metric, e.g. no
+	// throughput samples for p10 or no successful transfers for TTFB.
+	Skipped bool
+	Passed  bool
+}
+
+// Assertions is the result of Evaluate.
+type Assertions []Assertion
+
+// Failed reports whether any assertion failed.
+func (as Assertions) Failed() bool {
+	for _, a := range as {
+		if !a.Skipped && !a.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate checks d against th, returning one assertion per set threshold
+// named after its flag. p10 throughput comes from the per-second samples
+// and the TTFB p95 from successful transfers.
+func Evaluate(d Data, th Thresholds) Assertions {
+	var out Assertions
+	if th.MinAvgBps > 0 {
+		avg := d.Summary.AverageBps
+		out = append(out, Assertion{
+			Name:   "min-avg",
+			Limit:  ">= " + metrics.HumanBitsPerSecond(th.MinAvgBps),
+			Actual: metrics.HumanBitsPerSecond(avg),
+			Passed: avg >= th.MinAvgBps,
+		})
+	}
+	if th.MinP10Bps > 0 {
+		a := Assertion{Name: "min-p10", Limit: ">= " + metrics.HumanBitsPerSecond(th.MinP10Bps)}
+		if len(d.Samples) == 0 {
+			a.Skipped, a.Actual = true, "no samples"
+		} else {
+			p10 := metrics.Percentile(d.Samples, 10)
+			a.Actual, a.Passed = metrics.HumanBitsPerSecond(p10), p10 >= th.MinP10Bps
+		}
+		out = append(out, a)
+	}
+	if th.MaxErrorRate != nil {
+		rate := FromSummary(d.Summary, d.Generated).ErrorRate
+		out = append(out, Assertion{
+			Name:   "max-error-rate",
+			Limit:  fmt.Sprintf("<= %.2f%%", *th.MaxErrorRate*100),
+			Actual: fmt.Sprintf("%.2f%%", rate*100),
+			Passed: rate <= *th.MaxErrorRate,
+		})
+	}
+	if th.MaxTTFBP95 > 0 {
+		a := Assertion{Name: "max-ttfb-p95", Limit: "<= " + th.MaxTTFBP95.String()}
+		var ttfb []float64
+		for _, t := range d.Transfers {
+			if t.Outcome != metrics.FileFailed {
+				ttfb = append(ttfb, float64(t.TTFB))
+			}
+		}
+		if len(ttfb) == 0 {
+			a.Skipped, a.Actual = true, "no transfers"
+		} else {
+			p95 := time.Duration(metrics.Percentile(ttfb, 95))
+			a.Actual, a.Passed = p95.Round(100*time.Microsecond).String(), p95 <= th.MaxTTFBP95
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// WriteTable prints one line per assertion with its limit, the measured
+// value and PASS, FAIL or SKIP.
+func (as Assertions) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ASSERTION\tLIMIT\tACTUAL\tSTATUS")
+	for _, a := range as {
+		status := "PASS"
+		switch {
+		case a.Skipped:
+			status = "SKIP"
+		case !a.Passed:
+			status = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Name, a.Limit, a.Actual, status)
+	}
+	return tw.Flush()
+}
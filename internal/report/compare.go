@@ -0,0 +1,123 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// ExitRegression is the process exit status when a comparison regresses.
+const ExitRegression = 3
+
+// Default comparison tolerances.
+const (
+	DefaultThroughputTolerance = 0.10
+	DefaultErrorTolerance      = 0.01
+)
+
+// Tolerances bound how much worse the current run may be than the baseline.
+type Tolerances struct {
+	// Throughput is the allowed relative drop in average, peak and p95
+	// throughput (0.10 = 10%).
+	Throughput float64
+	// ErrorRate is the allowed absolute rise in error rate (0.01 = 1 point).
+	ErrorRate float64
+}
+
+// Delta compares one metric between the baseline and the current run.
+type Delta struct {
+	Metric   string
+	Baseline float64
+	Current  float64
+	// Skipped is set when the metric is missing from either run.
+	Skipped   bool
+	Regressed bool
+	bitrate   bool
+}
+
+// Change renders the difference: relative for throughput, in percentage
+// points for error rates.
+func (d Delta) Change() string {
+	if d.Skipped {
+		return "n/a"
+	}
+	if !d.bitrate {
+		return fmt.Sprintf("%+.2f pt", (d.Current-d.Baseline)*100)
+	}
+	if d.Baseline == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (d.Current-d.Baseline)/d.Baseline*100)
+}
+
+func (d Delta) format(v float64) string {
+	if d.Skipped && v == 0 {
+		return "-"
+	}
+	if d.bitrate {
+		return metrics.HumanBitsPerSecond(v)
+	}
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+// Comparison is the result of Compare.
+type Comparison struct {
+	Deltas []Delta
+}
+
+// Regressed reports whether any metric fell outside its tolerance.
+func (c Comparison) Regressed() bool {
+	for _, d := range c.Deltas {
+		if d.Regressed {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare checks cur against base. Throughput metrics regress when they
+// drop by more than tol.Throughput; p95 is skipped when either run has no
+// samples. The error rate regresses when it rises by more than tol.ErrorRate.
+func Compare(base, cur Report, tol Tolerances) Comparison {
+	var c Comparison
+	for _, m := range []struct {
+		name      string
+		base, cur float64
+	}{
+		{"Average", base.AvgBps, cur.AvgBps},
+		{"Peak", base.PeakBps, cur.PeakBps},
+		{"P95", base.P95Bps, cur.P95Bps},
+	} {
+		d := Delta{Metric: m.name, Baseline: m.base, Current: m.cur, bitrate: true}
+		if m.base <= 0 || (m.name == "P95" && m.cur <= 0) {
+			d.Skipped = true
+		} else {
+			d.Regressed = m.cur < m.base*(1-tol.Throughput)
+		}
+		c.Deltas = append(c.Deltas, d)
+	}
+
+	d := Delta{Metric: "Error Rate", Baseline: base.ErrorRate, Current: cur.ErrorRate}
+	d.Regressed = cur.ErrorRate > base.ErrorRate+tol.ErrorRate
+	c.Deltas = append(c.Deltas, d)
+	return c
+}
+
+// WriteTable prints the comparison as an aligned diff table.
+func (c Comparison) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tBASELINE\tCURRENT\tCHANGE\tSTATUS")
+	for _, d := range c.Deltas {
+		status := "ok"
+		switch {
+		case d.Skipped:
+			status = "skipped"
+		case d.Regressed:
+			status = "REGRESSED"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Metric, d.format(d.Baseline), d.format(d.Current), d.Change(), status)
+	}
+	return tw.Flush()
+}
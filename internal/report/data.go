@@ -0,0 +1,140 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// Data is what the file reports (HTML, JUnit) are rendered from.
+type Data struct {
+	Title     string
+	Generated time.Time
+	Summary   metrics.Summary
+	// Samples are bit/s per SampleInterval, from the printer or sampler.
+	Samples        []float64
+	SampleInterval time.Duration
+	Transfers      []metrics.Transfer
+}
+
+// Collect snapshots agg for reporting.
+func Collect(agg *metrics.Aggregator, title string) Data {
+	return Data{
+		Title:          title,
+		Generated:      time.Now(),
+		Summary:        agg.GetSummary(),
+		Samples:        agg.Samples(),
+		SampleInterval: time.Second,
+		Transfers:      agg.Transfers(),
+	}
+}
+
+// Failures returns the failed transfers in completion order.
+func (d Data) Failures() []metrics.Transfer {
+	var out []metrics.Transfer
+	for _, t := range d.Transfers {
+		if t.Outcome == metrics.FileFailed {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// HostStat aggregates the transfers to one host. Throughput and phase
+// averages cover successful transfers only.
+type HostStat struct {
+	Host   string
+	Files  int
+	Failed int
+	Bytes  int64
+	AvgBps float64
+
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+}
+
+// Hosts groups transfers by host, sorted by host name.
+func Hosts(transfers []metrics.Transfer) []HostStat {
+	byHost := map[string]*HostStat{}
+	ok := map[string]int{}
+	for _, t := range transfers {
+		h := byHost[t.Host]
+		if h == nil {
+			h = &HostStat{Host: t.Host}
+			byHost[t.Host] = h
+		}
+		h.Files++
+		h.Bytes += t.Bytes
+		if t.Outcome == metrics.FileFailed {
+			h.Failed++
+			continue
+		}
+		ok[t.Host]++
+		h.AvgBps += t.Bps()
+		h.DNS += t.DNS
+		h.Connect += t.Connect
+		h.TLS += t.TLS
+		h.TTFB += t.TTFB
+	}
+
+	out := make([]HostStat, 0, len(byHost))
+	for host, h := range byHost {
+		if n := ok[host]; n > 0 {
+			h.AvgBps /= float64(n)
+			h.DNS /= time.Duration(n)
+			h.Connect /= time.Duration(n)
+			h.TLS /= time.Duration(n)
+			h.TTFB /= time.Duration(n)
+		}
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// PhaseStat summarizes one latency phase across successful transfers.
+type PhaseStat struct {
+	Name string
+	Avg  time.Duration
+	P50  time.Duration
+	P95  time.Duration
+	Max  time.Duration
+}
+
+// Phases breaks latency down into DNS, connect, TLS and time to first byte.
+func Phases(transfers []metrics.Transfer) []PhaseStat {
+	phases := []struct {
+		name string
+		get  func(metrics.Transfer) time.Duration
+	}{
+		{"DNS", func(t metrics.Transfer) time.Duration { return t.DNS }},
+		{"Connect", func(t metrics.Transfer) time.Duration { return t.Connect }},
+		{"TLS", func(t metrics.Transfer) time.Duration { return t.TLS }},
+		{"TTFB", func(t metrics.Transfer) time.Duration { return t.TTFB }},
+	}
+	out := make([]PhaseStat, 0, len(phases))
+	for _, p := range phases {
+		var vals []float64
+		var sum time.Duration
+		for _, t := range transfers {
+			if t.Outcome == metrics.FileFailed {
+				continue
+			}
+			v := p.get(t)
+			vals = append(vals, float64(v))
+			sum += v
+		}
+		st := PhaseStat{Name: p.name}
+		if len(vals) > 0 {
+			st.Avg = sum / time.Duration(len(vals))
+			st.P50 = time.Duration(metrics.Percentile(vals, 50))
+			st.P95 = time.Duration(metrics.Percentile(vals, 95))
+			st.Max = time.Duration(metrics.Percentile(vals, 100))
+		}
+		out = append(out, st)
+	}
+	return out
+}
@@ -0,0 +1,270 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// Chart geometry in SVG user units.
+const (
+	chartWidth  = 800
+	chartHeight = 240
+	chartLeft   = 90
+	chartBottom = 24
+)
+
+// WriteHTMLFile renders d to path as a single self-contained HTML file.
+func WriteHTMLFile(path string, d Data) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteHTML(f, d); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteHTML renders d as a static HTML page with inline CSS, JS and SVG,
+// so it can be mailed or attached without any external assets.
+func WriteHTML(w io.Writer, d Data) error {
+	if d.Title == "" {
+		d.Title = "bandfetch report"
+	}
+	return htmlTemplate.Execute(w, struct {
+		Data
+		Rows     [][2]string
+		Chart    chart
+		Hosts    []HostStat
+		Phases   []PhaseStat
+		Failures []metrics.Transfer
+	}{
+		Data:     d,
+		Rows:     d.Summary.Rows(),
+		Chart:    newChart(d.Samples, d.SampleInterval),
+		Hosts:    Hosts(d.Transfers),
+		Phases:   Phases(d.Transfers),
+		Failures: d.Failures(),
+	})
+}
+
+type axisLabel struct {
+	X, Y float64
+	Text string
+}
+
+// chart is a pre-computed bandwidth line chart.
+type chart struct {
+	Width, Height   int
+	Left, Bottom    int
+	Points, Area    string
+	YLabels, XLabel []axisLabel
+	Empty           bool
+}
+
+func newChart(samples []float64, interval time.Duration) chart {
+	c := chart{Width: chartWidth, Height: chartHeight, Left: chartLeft, Bottom: chartHeight - chartBottom}
+	if len(samples) == 0 {
+		c.Empty = true
+		return c
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	peak := 0.0
+	for _, v := range samples {
+		peak = max(peak, v)
+	}
+	if peak == 0 {
+		peak = 1
+	}
+	plotW := float64(chartWidth - chartLeft - 10)
+	plotH := float64(c.Bottom - 10)
+	x := func(i int) float64 {
+		if len(samples) == 1 {
+			return float64(chartLeft) + plotW/2
+		}
+		return float64(chartLeft) + plotW*float64(i)/float64(len(samples)-1)
+	}
+	y := func(v float64) float64 { return float64(c.Bottom) - plotH*v/peak }
+
+	var pts strings.Builder
+	for i, v := range samples {
+		fmt.Fprintf(&pts, "%.1f,%.1f ", x(i), y(v))
+	}
+	c.Points = strings.TrimSpace(pts.String())
+	c.Area = fmt.Sprintf("%.1f,%d %s %.1f,%d", x(0), c.Bottom, c.Points, x(len(samples)-1), c.Bottom)
+
+	for _, f := range []float64{0, 0.5, 1} {
+		c.YLabels = append(c.YLabels, axisLabel{X: chartLeft - 6, Y: y(peak*f) + 4, Text: metrics.HumanBitsPerSecond(peak * f)})
+	}
+	span := time.Duration(len(samples)-1) * interval
+	c.XLabel = []axisLabel{
+		{X: x(0), Y: float64(chartHeight - 6), Text: "0s"},
+		{X: x(len(samples) - 1), Y: float64(chartHeight - 6), Text: span.String()},
+	}
+	return c
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bps":   metrics.HumanBitsPerSecond,
+	"bytes": func(n int64) string { return metrics.HumanBytes(float64(n)) },
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+	},
+	"secs": func(d time.Duration) string { return fmt.Sprintf("%.1fs", d.Seconds()) },
+	"when": func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.45 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+h1 { font-size: 1.5em; margin-bottom: 0; }
+h2 { font-size: 1.15em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: .2em; }
+h3 { font-size: 1em; margin: 1.2em 0 .4em; }
+.meta { color: #777; margin-top: .2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .3em .6em; border-bottom: 1px solid #eee; }
+th { background: #f6f6f6; cursor: pointer; user-select: none; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+table.summary { width: auto; }
+table.summary th { cursor: default; }
+.fail { color: #b00020; }
+svg text { font-size: 11px; fill: #666; }
+.line { fill: none; stroke: #2060c0; stroke-width: 1.5; }
+.area { fill: #2060c0; opacity: .12; }
+.axis { stroke: #bbb; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Generated {{when .Generated}}</p>
+
+<h2>Summary</h2>
+<table class="summary">
+{{- range .Rows}}
+<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Bandwidth</h2>
+{{- with .Chart}}
+{{- if .Empty}}
+<p>No throughput samples were recorded.</p>
+{{- else}}
+<svg viewBox="0 0 {{.Width}} {{.Height}}" width="100%" role="img" aria-label="bandwidth over time">
+<line class="axis" x1="{{.Left}}" y1="{{.Bottom}}" x2="{{.Width}}" y2="{{.Bottom}}"/>
+<line class="axis" x1="{{.Left}}" y1="0" x2="{{.Left}}" y2="{{.Bottom}}"/>
+<polygon class="area" points="{{.Area}}"/>
+<polyline class="line" points="{{.Points}}"/>
+{{- range .YLabels}}
+<text x="{{.X}}" y="{{.Y}}" text-anchor="end">{{.Text}}</text>
+{{- end}}
+{{- range .XLabel}}
+<text x="{{.X}}" y="{{.Y}}" text-anchor="middle">{{.Text}}</text>
+{{- end}}
+</svg>
+{{- end}}
+{{- end}}
+
+<h2>Hosts</h2>
+{{- if .Hosts}}
+<table class="sortable">
+<thead><tr><th>Host</th><th class="num">Files</th><th class="num">Failed</th><th class="num">Bytes</th><th class="num">Avg Throughput</th><th class="num">DNS</th><th class="num">Connect</th><th class="num">TLS</th><th class="num">TTFB</th></tr></thead>
+<tbody>
+{{- range .Hosts}}
+<tr><td>{{.Host}}</td><td class="num">{{.Files}}</td><td class="num{{if .Failed}} fail{{end}}">{{.Failed}}</td><td class="num" data-v="{{.Bytes}}">{{bytes .Bytes}}</td><td class="num" data-v="{{.AvgBps}}">{{bps .AvgBps}}</td><td class="num" data-v="{{.DNS.Nanoseconds}}">{{ms .DNS}}</td><td class="num" data-v="{{.Connect.Nanoseconds}}">{{ms .Connect}}</td><td class="num" data-v="{{.TLS.Nanoseconds}}">{{ms .TLS}}</td><td class="num" data-v="{{.TTFB.Nanoseconds}}">{{ms .TTFB}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- else}}
+<p>No downloads were recorded.</p>
+{{- end}}
+
+{{- with .Summary.Workers}}
+<h2>Workers</h2>
+<table class="sortable">
+<thead><tr><th class="num">Worker</th><th class="num">Files</th><th class="num">Bytes</th><th class="num">Active</th><th class="num">Idle</th><th class="num">Throughput</th></tr></thead>
+<tbody>
+{{- range .}}
+<tr><td class="num">{{.ID}}</td><td class="num">{{.Files}}</td><td class="num" data-v="{{.Bytes}}">{{bytes .Bytes}}</td><td class="num" data-v="{{.Active.Nanoseconds}}">{{secs .Active}}</td><td class="num" data-v="{{.Idle.Nanoseconds}}">{{secs .Idle}}</td><td class="num" data-v="{{.Bps}}">{{bps .Bps}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- with $.Summary.Fairness.SharedConns}}
+<p>{{.}} HTTP/2 connection(s) carried several workers' transfers, so those workers were not independent flows.</p>
+{{- end}}
+{{- end}}
+
+<h2>Latency Phases</h2>
+<table>
+<thead><tr><th>Phase</th><th class="num">Average</th><th class="num">p50</th><th class="num">p95</th><th class="num">Max</th></tr></thead>
+<tbody>
+{{- range .Phases}}
+<tr><td>{{.Name}}</td><td class="num">{{ms .Avg}}</td><td class="num">{{ms .P50}}</td><td class="num">{{ms .P95}}</td><td class="num">{{ms .Max}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+<h2>Failures</h2>
+{{- if .Failures}}
+<h3>By Class and Host</h3>
+<table class="sortable">
+<thead><tr><th>Class</th><th>Host</th><th class="num">Count</th></tr></thead>
+<tbody>
+{{- range .Summary.Failures}}
+<tr><td>{{.Class}}</td><td>{{.Host}}</td><td class="num fail">{{.Count}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<h3>Failed Downloads</h3>
+<table class="sortable">
+<thead><tr><th>URL</th><th class="num">Attempts</th><th>Class</th><th>Error</th></tr></thead>
+<tbody>
+{{- range .Failures}}
+<tr><td>{{.URL}}</td><td class="num">{{.Attempts}}</td><td>{{.Class}}</td><td class="fail">{{.Err}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- else}}
+<p>No failed downloads.</p>
+{{- end}}
+
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var body = th.closest("table").tBodies[0];
+    var rows = Array.from(body.rows);
+    var asc = th.dataset.dir !== "asc";
+    th.dataset.dir = asc ? "asc" : "desc";
+    var key = function (r) {
+      var c = r.cells[th.cellIndex];
+      var v = c.dataset.v !== undefined ? c.dataset.v : c.textContent;
+      var n = parseFloat(v);
+      return isNaN(n) ? v : n;
+    };
+    rows.sort(function (a, b) {
+      var x = key(a), y = key(b);
+      return (x < y ? -1 : x > y ? 1 : 0) * (asc ? 1 : -1);
+    });
+    rows.forEach(function (r) { body.appendChild(r); });
+  });
+});
+</script>
+</body>
+</html>
+`))
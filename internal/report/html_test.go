@@ -0,0 +1,85 @@
+package report
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+func sampleData() Data {
+	return Data{
+		Title:     "nightly",
+		Generated: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		Summary:   metrics.Summary{TotalSizeStr: "3.00 MiB", FilesDownloaded: 2, FilesFailed: 1},
+		Samples:   []float64{10e6, 80e6, 60e6},
+		Transfers: []metrics.Transfer{
+			{URL: "https://a.example/1", Host: "a.example", Outcome: metrics.FileDownloaded, Bytes: 1 << 20, Duration: time.Second, TTFB: 40 * time.Millisecond},
+			{URL: "https://a.example/2", Host: "a.example", Outcome: metrics.FileDownloaded, Bytes: 2 << 20, Duration: time.Second, TTFB: 60 * time.Millisecond},
+			{URL: "https://b.example/x", Host: "b.example", Outcome: metrics.FileFailed, Attempts: 4, Err: "unexpected status 503 <html>"},
+		},
+	}
+}
+
+func TestHostsAndPhases(t *testing.T) {
+	d := sampleData()
+	hosts := Hosts(d.Transfers)
+	if len(hosts) != 2 || hosts[0].Host != "a.example" || hosts[0].Files != 2 || hosts[1].Failed != 1 {
+		t.Fatalf("unexpected hosts: %+v", hosts)
+	}
+	if hosts[0].TTFB != 50*time.Millisecond {
+		t.Fatalf("expected mean TTFB of successful transfers, got %v", hosts[0].TTFB)
+	}
+	for _, p := range Phases(d.Transfers) {
+		if p.Name == "TTFB" && (p.P50 != 40*time.Millisecond || p.Max != 60*time.Millisecond) {
+			t.Fatalf("unexpected TTFB phase: %+v", p)
+		}
+	}
+}
+
+func TestWriteHTMLIsSelfContained(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, sampleData()); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	page := buf.String()
+	for _, want := range []string{"<polyline", "a.example", "Total Downloaded", "3.00 MiB", "status 503 &lt;html&gt;", "<script>"} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("expected %q in report", want)
+		}
+	}
+	for _, external := range []string{"src=", "href=", "@import"} {
+		if strings.Contains(page, external) {
+			t.Fatalf("report must not reference external assets (%s)", external)
+		}
+	}
+
+	if err := WriteHTMLFile(filepath.Join(t.TempDir(), "out", "r.html"), Data{}); err != nil {
+		t.Fatalf("empty report: %v", err)
+	}
+}
+
+func TestWriteHTMLWorkers(t *testing.T) {
+	d := sampleData()
+	d.Summary.Workers = []metrics.WorkerStat{
+		{ID: 1, Files: 2, Bytes: 3 << 20, Active: 2 * time.Second, Idle: 500 * time.Millisecond},
+		{ID: 2, Files: 1, Active: time.Second},
+	}
+	d.Summary.Fairness = metrics.FairnessOf(d.Summary.Workers)
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, d); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{"<h2>Workers</h2>", "Worker Fairness", "0.5s", "12.58 Mbit/s"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in report", want)
+		}
+	}
+	r := FromSummary(d.Summary, d.Generated)
+	if r.Fairness == nil || r.Fairness.Workers != 2 || r.Fairness.Jain != 0.5 {
+		t.Fatalf("unexpected JSON fairness: %+v", r.Fairness)
+	}
+}
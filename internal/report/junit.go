@@ -0,0 +1,155 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// JUnitOptions tune the JUnit report.
+type JUnitOptions struct {
+	// SuiteName names the test suite (default "bandfetch").
+	SuiteName string
+	// MinBps fails downloads slower than this many bit/s; 0 disables it.
+	MinBps float64
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitFile writes the JUnit report for d to path.
+func WriteJUnitFile(path string, d Data, opts JUnitOptions) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteJUnit(f, d, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteJUnit renders each download as a test case named after its URL and
+// classed by host. Failed downloads fail with their error, downloads slower
+// than opts.MinBps fail as threshold violations, and unchanged or skipped
+// files are reported as skipped.
+func WriteJUnit(w io.Writer, d Data, opts JUnitOptions) error {
+	suite := junitSuite{
+		Name:      opts.SuiteName,
+		Time:      seconds(d.Summary.Elapsed),
+		Timestamp: d.Generated.UTC().Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{"total_bytes", strconv.FormatInt(d.Summary.TotalBytes, 10)},
+			{"avg_bps", strconv.FormatFloat(d.Summary.AverageBps, 'f', 0, 64)},
+			{"peak_bps", strconv.FormatFloat(d.Summary.PeakBps, 'f', 0, 64)},
+		},
+	}
+	if suite.Name == "" {
+		suite.Name = "bandfetch"
+	}
+	for _, f := range d.Summary.Failures {
+		name := "failures." + strings.ReplaceAll(string(f.Class), " ", "_") + "." + f.Host
+		suite.Properties = append(suite.Properties, junitProperty{name, strconv.Itoa(f.Count)})
+	}
+	if opts.MinBps > 0 {
+		suite.Properties = append(suite.Properties, junitProperty{"min_bps", strconv.FormatFloat(opts.MinBps, 'f', 0, 64)})
+	}
+
+	for _, t := range d.Transfers {
+		tc := junitCase{
+			Name:      t.URL,
+			Classname: t.Host,
+			Time:      seconds(t.Duration),
+			Properties: []junitProperty{
+				{"bytes", strconv.FormatInt(t.Bytes, 10)},
+				{"throughput_bps", strconv.FormatFloat(t.Bps(), 'f', 0, 64)},
+				{"ttfb_ms", strconv.FormatFloat(float64(t.TTFB)/float64(time.Millisecond), 'f', 1, 64)},
+				{"attempts", strconv.Itoa(t.Attempts)},
+			},
+		}
+		switch t.Outcome {
+		case metrics.FileFailed:
+			kind := "download"
+			if t.Class != "" {
+				kind = string(t.Class)
+			}
+			tc.Failure = &junitMessage{Message: t.Err, Type: kind, Text: t.Err}
+			suite.Failures++
+		case metrics.FileUnchanged:
+			tc.Skipped = &junitMessage{Message: "not modified"}
+			suite.Skipped++
+		case metrics.FileSkipped:
+			tc.Skipped = &junitMessage{Message: "destination exists"}
+			suite.Skipped++
+		default:
+			if opts.MinBps > 0 && t.Bps() < opts.MinBps {
+				msg := fmt.Sprintf("throughput %s is below the minimum %s",
+					metrics.HumanBitsPerSecond(t.Bps()), metrics.HumanBitsPerSecond(opts.MinBps))
+				tc.Failure = &junitMessage{Message: msg, Type: "threshold", Text: msg}
+				suite.Failures++
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
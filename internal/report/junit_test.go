@@ -0,0 +1,54 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	d := sampleData()
+	d.Transfers = append(d.Transfers, metrics.Transfer{URL: "https://a.example/3", Host: "a.example", Outcome: metrics.FileUnchanged})
+	// 1 MiB in 1s is ~8.4 Mbit/s and 2 MiB in 1s ~16.8 Mbit/s.
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, d, JUnitOptions{MinBps: 10e6}); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	var parsed junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	suite := parsed.Suites[0]
+	if suite.Name != "bandfetch" || suite.Tests != 4 || suite.Failures != 2 || suite.Skipped != 1 {
+		t.Fatalf("unexpected suite counts: %+v", suite)
+	}
+	slow, fast, failed := suite.Cases[0], suite.Cases[1], suite.Cases[2]
+	if slow.Failure == nil || slow.Failure.Type != "threshold" {
+		t.Fatalf("expected a threshold failure for the slow download, got %+v", slow.Failure)
+	}
+	if fast.Failure != nil || fast.Time != "1.000" || fast.Classname != "a.example" {
+		t.Fatalf("unexpected passing case: %+v", fast)
+	}
+	if failed.Failure == nil || failed.Failure.Type != "download" || !strings.Contains(failed.Failure.Message, "503") {
+		t.Fatalf("expected the download error, got %+v", failed.Failure)
+	}
+	if suite.Cases[3].Skipped == nil {
+		t.Fatalf("expected unchanged file to be skipped")
+	}
+}
+
+func TestWriteJUnitWithoutThreshold(t *testing.T) {
+	d := Data{Transfers: []metrics.Transfer{{URL: "u", Outcome: metrics.FileDownloaded, Bytes: 1, Duration: time.Hour}}}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, d, JUnitOptions{SuiteName: "edge"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `failures="0"`) || !strings.Contains(buf.String(), `name="edge"`) {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
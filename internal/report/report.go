@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+// Version is the schema version written to JSON reports.
+const Version = 1
+
+// Report is the machine-readable form of a run's metrics.Summary, written
+// by -json-report and read back by compare and -baseline.
+type Report struct {
+	Version        int       `json:"version"`
+	Time           time.Time `json:"time"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	TotalBytes     int64     `json:"total_bytes"`
+	AvgBps         float64   `json:"avg_bps"`
+	PeakBps        float64   `json:"peak_bps"`
+	// P95Bps is 0 when no throughput samples were taken.
+	P95Bps float64 `json:"p95_bps"`
+	// Goodput counts successful downloads only; the fields above include
+	// failed and retried attempts.
+	GoodputBytes int64   `json:"goodput_bytes"`
+	GoodputBps   float64 `json:"goodput_bps"`
+
+	FilesDownloaded int64 `json:"files_downloaded"`
+	FilesUnchanged  int64 `json:"files_unchanged"`
+	FilesSkipped    int64 `json:"files_skipped"`
+	FilesFailed     int64 `json:"files_failed"`
+	// ErrorRate is failed downloads over all finished downloads.
+	ErrorRate float64 `json:"error_rate"`
+	// Aborted says why a failure budget cut the run short.
+	Aborted string `json:"aborted,omitempty"`
+	// Failures breaks failed downloads down by error class and host.
+	Failures []Failure `json:"failures,omitempty"`
+	// Fairness is set when more than one worker ran.
+	Fairness *Fairness `json:"fairness,omitempty"`
+}
+
+// Fairness is metrics.Fairness: how evenly workers shared throughput.
+type Fairness struct {
+	Workers   int     `json:"workers"`
+	Jain      float64 `json:"jain_index"`
+	MinBps    float64 `json:"min_bps"`
+	MedianBps float64 `json:"median_bps"`
+	MaxBps    float64 `json:"max_bps"`
+	// SharedConns is set when workers were multiplexed onto shared HTTP/2
+	// connections, so they were not independent flows.
+	SharedConns int `json:"shared_connections,omitempty"`
+}
+
+// Failure is one row of the failure breakdown.
+type Failure struct {
+	Class string `json:"class"`
+	Host  string `json:"host"`
+	Count int    `json:"count"`
+}
+
+// FromSummary converts a summary taken at t.
+func FromSummary(s metrics.Summary, t time.Time) Report {
+	r := Report{
+		Version:         Version,
+		Time:            t.UTC(),
+		ElapsedSeconds:  s.Elapsed.Seconds(),
+		TotalBytes:      s.TotalBytes,
+		AvgBps:          s.AverageBps,
+		PeakBps:         s.PeakBps,
+		P95Bps:          s.P95Bps,
+		GoodputBytes:    s.GoodputBytes,
+		GoodputBps:      s.GoodputBps,
+		FilesDownloaded: s.FilesDownloaded,
+		FilesUnchanged:  s.FilesUnchanged,
+		FilesSkipped:    s.FilesSkipped,
+		FilesFailed:     s.FilesFailed,
+		Aborted:         s.Aborted,
+	}
+	for _, f := range s.Failures {
+		r.Failures = append(r.Failures, Failure{Class: string(f.Class), Host: f.Host, Count: f.Count})
+	}
+	if f := s.Fairness; f.Workers > 1 {
+		r.Fairness = &Fairness{Workers: f.Workers, Jain: f.Jain, MinBps: f.MinBps, MedianBps: f.MedianBps, MaxBps: f.MaxBps, SharedConns: f.SharedConns}
+	}
+	if total := r.Files(); total > 0 {
+		r.ErrorRate = float64(r.FilesFailed) / float64(total)
+	}
+	return r
+}
+
+// Files is the number of finished downloads of any outcome.
+func (r Report) Files() int64 {
+	return r.FilesDownloaded + r.FilesUnchanged + r.FilesSkipped + r.FilesFailed
+}
+
+// Write saves r as indented JSON, creating parent directories as needed.
+func Write(path string, r Report) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Load reads a report saved by Write.
+func Load(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Report{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if r.Version == 0 || r.Version > Version {
+		return Report{}, fmt.Errorf("%s: unsupported report version %d", path, r.Version)
+	}
+	return r, nil
+}
@@ -0,0 +1,158 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/metrics"
+)
+
+func TestWriteLoadRoundTrip(t *testing.T) {
+	s := metrics.Summary{
+		TotalBytes:      1 << 20,
+		Elapsed:         2 * time.Second,
+		AverageBps:      4e6,
+		PeakBps:         6e6,
+		P95Bps:          5.5e6,
+		FilesDownloaded: 3,
+		FilesFailed:     1,
+		Failures:        []metrics.FailureStat{{Class: metrics.ClassDNS, Host: "a.example", Count: 1}},
+	}
+	path := filepath.Join(t.TempDir(), "nightly", "report.json")
+	want := FromSummary(s, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err := Write(path, want); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+	if got.ErrorRate != 0.25 {
+		t.Fatalf("expected error rate 0.25, got %v", got.ErrorRate)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := Report{Version: Version, AvgBps: 100e6, PeakBps: 120e6, P95Bps: 115e6, ErrorRate: 0.01}
+	tol := Tolerances{Throughput: 0.10, ErrorRate: 0.01}
+
+	ok := Compare(base, Report{AvgBps: 95e6, PeakBps: 130e6, P95Bps: 110e6, ErrorRate: 0.015}, tol)
+	if ok.Regressed() {
+		t.Fatalf("expected no regression within tolerance: %+v", ok.Deltas)
+	}
+
+	bad := Compare(base, Report{AvgBps: 80e6, PeakBps: 120e6, ErrorRate: 0.05}, tol)
+	if !bad.Regressed() {
+		t.Fatalf("expected a regression")
+	}
+	var regressed []string
+	for _, d := range bad.Deltas {
+		if d.Regressed {
+			regressed = append(regressed, d.Metric)
+		}
+	}
+	if strings.Join(regressed, ",") != "Average,Error Rate" {
+		t.Fatalf("unexpected regressions: %v", regressed)
+	}
+
+	var buf bytes.Buffer
+	if err := bad.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"REGRESSED", "-20.0%", "skipped", "+4.00 pt"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in table:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	d := sampleData()
+	d.Summary.AverageBps = 600e6
+	maxErr := 0.01
+	as := Evaluate(d, Thresholds{
+		MinAvgBps:    500e6,
+		MinP10Bps:    20e6,
+		MaxErrorRate: &maxErr,
+		MaxTTFBP95:   100 * time.Millisecond,
+	})
+	got := map[string]bool{}
+	for _, a := range as {
+		got[a.Name] = a.Passed
+	}
+	want := map[string]bool{"min-avg": true, "min-p10": false, "max-error-rate": false, "max-ttfb-p95": true}
+	if len(as) != len(want) {
+		t.Fatalf("expected %d assertions, got %+v", len(want), as)
+	}
+	for name, passed := range want {
+		if got[name] != passed {
+			t.Errorf("%s: passed = %v, want %v", name, got[name], passed)
+		}
+	}
+	if !as.Failed() {
+		t.Fatalf("expected the run to fail its thresholds")
+	}
+
+	var buf bytes.Buffer
+	if err := as.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "33.33%") || !strings.Contains(buf.String(), "FAIL") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+
+	d.Samples, d.Transfers = nil, nil
+	as = Evaluate(d, Thresholds{MinP10Bps: 1, MaxTTFBP95: time.Second})
+	if as.Failed() || !as[0].Skipped || !as[1].Skipped {
+		t.Fatalf("expected assertions without data to be skipped: %+v", as)
+	}
+	if Evaluate(d, Thresholds{}) != nil {
+		t.Fatalf("expected no assertions without thresholds")
+	}
+}
+
+func TestMinP10WithSamplerOnly(t *testing.T) {
+	// No progress printer: the sampler alone must supply the p10.
+	agg := metrics.NewAggregator()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	metrics.StartSampler(ctx, agg, 5*time.Millisecond, &wg)
+	for i := 0; i < 10; i++ {
+		agg.AddBytes(1 << 10)
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	as := Evaluate(Collect(agg, "t"), Thresholds{MinP10Bps: 1e12})
+	if len(as) != 1 || as[0].Skipped || as[0].Passed {
+		t.Fatalf("expected min-p10 to be evaluated and fail, got %+v", as)
+	}
+}
+
+func TestStatusExitCode(t *testing.T) {
+	tests := []struct {
+		s    Status
+		want int
+	}{
+		{Status{}, 0},
+		{Status{Failed: true}, ExitFailed},
+		{Status{Failed: true, Regressed: true}, ExitRegression},
+		{Status{Regressed: true, Violated: true}, ExitThreshold},
+		{Status{Failed: true, Violated: true, Interrupted: true}, ExitInterrupted},
+	}
+	for _, tt := range tests {
+		if got := tt.s.ExitCode(); got != tt.want {
+			t.Errorf("%+v: exit code %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package sweep
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cx009/netperf/internal/downloader"
+	"github.com/cx009/netperf/internal/metrics"
+	"github.com/cx009/netperf/internal/urls"
+)
+
+// DefaultSampleInterval is how often throughput is sampled for peak/p95.
+const DefaultSampleInterval = time.Second
+
+// Step is one setting in a sweep.
+type Step struct {
+	Workers  int
+	Protocol downloader.Protocol
+}
+
+// Options configures a sweep.
+type Options struct {
+	Workers   []int
+	Protocols []downloader.Protocol
+	// Duration is how long each step runs; the list is cycled until then.
+	Duration time.Duration
+	// Timeout bounds the TLS handshake and the wait for response headers;
+	// bodies are guarded by Downloader.StallTimeout instead.
+	Timeout time.Duration
+	// Downloader is used for every step; bodies should be discarded.
+	Downloader     downloader.Options
+	SampleInterval time.Duration
+	// Progress receives a line as each step starts and finishes.
+	Progress io.Writer
+}
+
+// Result holds the measurements of one step.
+type Result struct {
+	Step
+	Elapsed   time.Duration
+	Bytes     int64
+	AvgBps    float64
+	PeakBps   float64
+	P95Bps    float64
+	Files     int64
+	Failed    int64
+	ErrorRate float64
+}
+
+// Steps expands the options into the ordered list of settings: every
+// worker count for the first protocol, then for the next.
+func (o Options) Steps() []Step {
+	protos := o.Protocols
+	if len(protos) == 0 {
+		protos = []downloader.Protocol{downloader.ProtocolHTTP2}
+	}
+	var steps []Step
+	for _, p := range protos {
+		for _, w := range o.Workers {
+			steps = append(steps, Step{Workers: w, Protocol: p})
+		}
+	}
+	return steps
+}
+
+// Run measures each step with a fresh client, Manager and Aggregator. If
+// ctx is cancelled the completed steps are returned with ctx's error.
+func Run(ctx context.Context, entries []urls.Entry, opts Options) ([]Result, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("sweep: empty URL list")
+	}
+	if opts.Duration <= 0 {
+		return nil, errors.New("sweep: step duration must be greater than 0")
+	}
+	if opts.SampleInterval <= 0 {
+		opts.SampleInterval = DefaultSampleInterval
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = io.Discard
+	}
+
+	var results []Result
+	for _, step := range opts.Steps() {
+		fmt.Fprintf(progress, "[SWEEP] workers=%d protocol=%s for %s\n", step.Workers, step.Protocol, opts.Duration)
+		res, err := runStep(ctx, entries, step, opts)
+		if err != nil {
+			return results, err
+		}
+		fmt.Fprintf(progress, "[SWEEP] workers=%d protocol=%s avg=%s errors=%.1f%%\n",
+			step.Workers, step.Protocol, metrics.HumanBitsPerSecond(res.AvgBps), res.ErrorRate*100)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func runStep(ctx context.Context, entries []urls.Entry, step Step, opts Options) (Result, error) {
+	agg := metrics.NewAggregator()
+	dl := downloader.New(downloader.NewHTTPClientFor(opts.Timeout, step.Protocol), agg, opts.Downloader)
+	defer dl.Close()
+	mgr := downloader.NewManager(dl, step.Workers)
+	mgr.SetOutput(io.Discard)
+
+	stepCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	var wg sync.WaitGroup
+	metrics.StartSampler(stepCtx, agg, opts.SampleInterval, &wg)
+	// Failures are part of the measurement, so the error is not fatal.
+	_ = mgr.RunLoop(stepCtx, entries)
+	cancel()
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	s := agg.GetSummary()
+	res := Result{
+		Step:    step,
+		Elapsed: s.Elapsed,
+		Bytes:   s.TotalBytes,
+		AvgBps:  s.AverageBps,
+		PeakBps: s.PeakBps,
+		P95Bps:  s.P95Bps,
+		Files:   s.FilesDownloaded + s.FilesUnchanged + s.FilesSkipped + s.FilesFailed,
+		Failed:  s.FilesFailed,
+	}
+	if res.Files > 0 {
+		res.ErrorRate = float64(res.Failed) / float64(res.Files)
+	}
+	return res, nil
+}
+
+// WriteTable renders results as an aligned table.
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKERS\tPROTOCOL\tAVERAGE\tPEAK\tP95\tFILES\tERRORS")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%.1f%%\n",
+			r.Workers, r.Protocol,
+			metrics.HumanBitsPerSecond(r.AvgBps),
+			metrics.HumanBitsPerSecond(r.PeakBps),
+			metrics.HumanBitsPerSecond(r.P95Bps),
+			r.Files, r.ErrorRate*100)
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes results as CSV with raw bit/s values.
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"workers", "protocol", "duration_s", "bytes", "avg_bps", "peak_bps", "p95_bps", "files", "failed", "error_rate"})
+	for _, r := range results {
+		cw.Write([]string{
+			strconv.Itoa(r.Workers),
+			string(r.Protocol),
+			strconv.FormatFloat(r.Elapsed.Seconds(), 'f', 3, 64),
+			strconv.FormatInt(r.Bytes, 10),
+			strconv.FormatFloat(r.AvgBps, 'f', 0, 64),
+			strconv.FormatFloat(r.PeakBps, 'f', 0, 64),
+			strconv.FormatFloat(r.P95Bps, 'f', 0, 64),
+			strconv.FormatInt(r.Files, 10),
+			strconv.FormatInt(r.Failed, 10),
+			strconv.FormatFloat(r.ErrorRate, 'f', 4, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseWorkers parses a worker list: comma-separated counts ("1,2,4,8")
+// and/or doubling ranges ("1..64" is 1,2,4,...,64).
+func ParseWorkers(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if from, to, ok := strings.Cut(part, ".."); ok {
+			lo, err1 := strconv.Atoi(from)
+			hi, err2 := strconv.Atoi(to)
+			if err1 != nil || err2 != nil || lo < 1 || hi < lo {
+				return nil, fmt.Errorf("invalid worker range %q", part)
+			}
+			// Stop doubling at hi/2 so n*2 cannot overflow near MaxInt.
+			for n := lo; n < hi; n *= 2 {
+				out = append(out, n)
+				if n > hi/2 {
+					break
+				}
+			}
+			out = append(out, hi)
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid worker count %q", part)
+		}
+		out = append(out, n)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no worker counts given")
+	}
+	return out, nil
+}
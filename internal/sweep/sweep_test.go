@@ -0,0 +1,96 @@
+package sweep
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cx009/netperf/internal/downloader"
+	"github.com/cx009/netperf/internal/urls"
+)
+
+func TestParseWorkers(t *testing.T) {
+	got, err := ParseWorkers("1..16, 24")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{1, 2, 4, 8, 16, 24}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, _ := ParseWorkers("3..10"); !reflect.DeepEqual(got, []int{3, 6, 10}) {
+		t.Fatalf("range should end at its upper bound, got %v", got)
+	}
+	got, err = ParseWorkers("1.." + strconv.Itoa(math.MaxInt))
+	if err != nil || len(got) != strconv.IntSize || got[len(got)-1] != math.MaxInt {
+		t.Fatalf("expected doubling up to MaxInt without overflow, got %v (%v)", got, err)
+	}
+	for i := 1; i < len(got)-1; i++ {
+		if got[i] != 2*got[i-1] {
+			t.Fatalf("expected doubling at %d, got %v", i, got)
+		}
+	}
+	for _, bad := range []string{"", "0", "x", "8..2"} {
+		if _, err := ParseWorkers(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestRunMeasuresEachStep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(make([]byte, 16<<10))
+	}))
+	t.Cleanup(srv.Close)
+
+	entries := []urls.Entry{
+		{Mirrors: []string{srv.URL + "/ok"}, Index: 1},
+		{Mirrors: []string{srv.URL + "/bad"}, Index: 2},
+	}
+	opts := Options{
+		Workers:        []int{1, 4},
+		Protocols:      []downloader.Protocol{downloader.ProtocolHTTP1, downloader.ProtocolHTTP2},
+		Duration:       150 * time.Millisecond,
+		Timeout:        5 * time.Second,
+		SampleInterval: 25 * time.Millisecond,
+	}
+	results, err := Run(context.Background(), entries, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 steps, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Bytes == 0 || r.AvgBps <= 0 || r.P95Bps <= 0 {
+			t.Fatalf("step %+v recorded no throughput", r.Step)
+		}
+		if r.Failed == 0 || r.ErrorRate <= 0 || r.ErrorRate >= 1 {
+			t.Fatalf("step %+v: expected a partial error rate, got %d/%d", r.Step, r.Failed, r.Files)
+		}
+	}
+
+	var table, csv bytes.Buffer
+	if err := WriteTable(&table, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteCSV(&csv, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(table.String(), "http1") || !strings.HasPrefix(csv.String(), "workers,protocol,") {
+		t.Fatalf("unexpected output:\n%s\n%s", table.String(), csv.String())
+	}
+	if lines := strings.Count(csv.String(), "\n"); lines != 5 {
+		t.Fatalf("expected header plus 4 CSV rows, got %d lines", lines)
+	}
+}
@@ -0,0 +1,262 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxExpansion caps how many URLs a single list file may expand to.
+const MaxExpansion = 1000000
+
+var errTooLarge = fmt.Errorf("expansion exceeds %d URLs", MaxExpansion)
+
+var rangeExpr = regexp.MustCompile(`^(-?\d+)\.\.(-?\d+)$`)
+
+// Expand applies environment substitution (${NAME}) and brace expansion
+// ({0001..2000} ranges and {a,b,c} alternation) to a single list entry,
+// producing at most limit URLs. A backslash before one of $ { } , \ makes
+// that character literal.
+func Expand(line string, limit int) ([]string, error) {
+	subst, err := substituteEnv(line)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 {
+		return nil, errTooLarge
+	}
+	out, err := expandBraces(subst, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > limit {
+		return nil, errTooLarge
+	}
+	for i, u := range out {
+		out[i] = unescape(u)
+	}
+	return out, nil
+}
+
+// escaped reports whether in[i] is a backslash escaping the next character.
+func escaped(in string, i int) bool {
+	return in[i] == '\\' && i+1 < len(in) && strings.IndexByte(`${},\`, in[i+1]) >= 0
+}
+
+// unescape drops the backslash from each escape sequence.
+func unescape(in string) string {
+	if strings.IndexByte(in, '\\') < 0 {
+		return in
+	}
+	var b strings.Builder
+	for i := 0; i < len(in); i++ {
+		if escaped(in, i) {
+			i++
+		}
+		b.WriteByte(in[i])
+	}
+	return b.String()
+}
+
+// indexUnescaped returns the index of the first instance of sub in in that
+// does not start with an escaped character, or -1.
+func indexUnescaped(in, sub string) int {
+	for i := 0; i < len(in); i++ {
+		if escaped(in, i) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(in[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// substituteEnv replaces ${NAME} references with environment values.
+// Unset variables are reported rather than silently expanded to "".
+func substituteEnv(in string) (string, error) {
+	if !strings.Contains(in, "${") {
+		return in, nil
+	}
+	var b strings.Builder
+	for {
+		idx := indexUnescaped(in, "${")
+		if idx < 0 {
+			b.WriteString(in)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(in[idx:], '}')
+		if end < 0 {
+			return "", errors.New("unterminated ${ in variable reference")
+		}
+		name := in[idx+2 : idx+end]
+		if name == "" {
+			return "", errors.New("empty variable name in ${}")
+		}
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(in[:idx])
+		b.WriteString(val)
+		in = in[idx+end+1:]
+	}
+}
+
+func expandBraces(in string, limit int) ([]string, error) {
+	start, end, ok, err := findGroup(in)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []string{in}, nil
+	}
+
+	prefix, body, suffix := in[:start], in[start+1:end], in[end+1:]
+	alts, err := groupAlternatives(body, limit)
+	if err != nil {
+		return nil, err
+	}
+	if alts == nil {
+		// Not an expression (e.g. "{}" or "{x}"); keep the braces literally
+		// and expand whatever follows.
+		rest, err := expandBraces(suffix, limit)
+		if err != nil {
+			return nil, err
+		}
+		head := in[:end+1]
+		out := make([]string, len(rest))
+		for i, r := range rest {
+			out[i] = head + r
+		}
+		return out, nil
+	}
+
+	rest, err := expandBraces(suffix, limit)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, alt := range alts {
+		// Alternatives may contain nested groups of their own.
+		inner, err := expandBraces(alt, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(out)+len(inner)*len(rest) > limit {
+			return nil, errTooLarge
+		}
+		for _, i := range inner {
+			for _, r := range rest {
+				out = append(out, prefix+i+r)
+			}
+		}
+	}
+	return out, nil
+}
+
+// findGroup locates the first top-level brace group in the input.
+func findGroup(in string) (start, end int, ok bool, err error) {
+	start = indexUnescaped(in, "{")
+	if start < 0 {
+		if indexUnescaped(in, "}") >= 0 {
+			return 0, 0, false, errors.New("unmatched '}'")
+		}
+		return 0, 0, false, nil
+	}
+	depth := 0
+	for i := start; i < len(in); i++ {
+		if escaped(in, i) {
+			i++
+			continue
+		}
+		switch in[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return start, i, true, nil
+			}
+		}
+	}
+	return 0, 0, false, errors.New("unmatched '{'")
+}
+
+// groupAlternatives returns the values a brace body expands to, or nil when
+// the body is neither a range nor a comma-separated list.
+func groupAlternatives(body string, limit int) ([]string, error) {
+	if m := rangeExpr.FindStringSubmatch(body); m != nil {
+		return expandRange(m[1], m[2], limit)
+	}
+
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(body); i++ {
+		if escaped(body, i) {
+			i++
+			continue
+		}
+		switch body[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, body[last:i])
+				last = i + 1
+			}
+		}
+	}
+	if parts == nil {
+		return nil, nil
+	}
+	return append(parts, body[last:]), nil
+}
+
+// expandRange expands a numeric range, zero-padding to the widest endpoint
+// when either endpoint carries a leading zero (chunk-{0001..2000}).
+func expandRange(from, to string, limit int) ([]string, error) {
+	lo, err := strconv.Atoi(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range start %q", from)
+	}
+	hi, err := strconv.Atoi(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range end %q", to)
+	}
+
+	// The span is computed in uint64 so that ranges covering most of the
+	// int domain neither wrap around nor overflow the slice capacity.
+	step := 1
+	span := uint64(hi) - uint64(lo)
+	if hi < lo {
+		step = -1
+		span = uint64(lo) - uint64(hi)
+	}
+	if span >= uint64(limit) {
+		return nil, errTooLarge
+	}
+	count := int(span) + 1
+
+	width := 0
+	if padded(from) || padded(to) {
+		width = max(len(from), len(to))
+	}
+
+	out := make([]string, 0, count)
+	for i, n := 0, lo; i < count; i, n = i+1, n+step {
+		out = append(out, fmt.Sprintf("%0*d", width, n))
+	}
+	return out, nil
+}
+
+func padded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
@@ -0,0 +1,117 @@
+package urls
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandRangePadded(t *testing.T) {
+	got, err := Expand("https://host/chunk-{0001..0003}.bin", MaxExpansion)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"https://host/chunk-0001.bin",
+		"https://host/chunk-0002.bin",
+		"https://host/chunk-0003.bin",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestExpandAlternationAndEnv(t *testing.T) {
+	t.Setenv("MIRROR", "mirror.example")
+	got, err := Expand("https://${MIRROR}/{a,b{1..2}}/x", MaxExpansion)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"https://mirror.example/a/x",
+		"https://mirror.example/b1/x",
+		"https://mirror.example/b2/x",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestExpandEscapes(t *testing.T) {
+	t.Setenv("MIRROR", "m.example")
+	got, err := Expand(`https://${MIRROR}/q\{id\}/\${HOME}/{a\,b,c}/\\x`, MaxExpansion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`https://m.example/q{id}/${HOME}/a,b/\x`, `https://m.example/q{id}/${HOME}/c/\x`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// A lone escaped brace is not unmatched.
+	if got, err := Expand(`https://host/a\}b`, MaxExpansion); err != nil || got[0] != "https://host/a}b" {
+		t.Fatalf("got %v, %v", got, err)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	cases := []string{
+		"https://${BANDFETCH_TEST_UNSET}/x",
+		"https://host/{a,b",
+		"https://host/{1..10}",
+		"https://host/{-9000000000000000000..9000000000000000000}",
+		"https://host/{-9223372036854775808..9223372036854775807}",
+		"https://host/{9223372036854775807..-9223372036854775808}",
+	}
+	for _, c := range cases {
+		if _, err := Expand(c, 5); err == nil {
+			t.Fatalf("expected error for %q", c)
+		}
+	}
+}
+
+func TestLoadReportsLineNumber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	body := "# comment\nhttps://host/{1..2}\n\nhttps://host/{oops\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	_, err := Load(path)
+	if err == nil {
+		t.Fatalf("expected error for malformed template")
+	}
+	if !strings.Contains(err.Error(), ":4:") {
+		t.Fatalf("expected line 4 in error, got %v", err)
+	}
+}
+
+func TestLoadEntriesZipsMirrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	body := "https://a/c-{1..2}.bin  https://b/c-{1..2}.bin\nhttps://a/single.bin\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	entries, err := LoadEntries(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Entry{
+		{Mirrors: []string{"https://a/c-1.bin", "https://b/c-1.bin"}, Index: 1},
+		{Mirrors: []string{"https://a/c-2.bin", "https://b/c-2.bin"}, Index: 2},
+		{Mirrors: []string{"https://a/single.bin"}, Index: 3},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("expected %v, got %v", want, entries)
+	}
+}
+
+func TestLoadEntriesMismatchedMirrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte("https://a/{1..2} https://b/{1..3}\n"), 0o644); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	if _, err := LoadEntries(path); err == nil {
+		t.Fatalf("expected error for mismatched mirror expansion")
+	}
+}
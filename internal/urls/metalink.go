@@ -0,0 +1,143 @@
+package urls
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Checksum is an expected content digest in hex.
+type Checksum struct {
+	Algorithm string // normalised: "sha512", "sha256", "sha1" or "md5"
+	Value     string
+}
+
+// hashPreference lists supported digests from strongest to weakest.
+var hashPreference = []string{"sha512", "sha256", "sha1", "md5"}
+
+// IsMetalink reports whether path names a Metalink document.
+func IsMetalink(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".meta4", ".metalink":
+		return true
+	}
+	return false
+}
+
+// metalinkDoc covers both RFC 5854 (Metalink 4) and the older 3.0 layout;
+// element names are matched regardless of namespace.
+type metalinkDoc struct {
+	Files   []metalinkFile `xml:"file"`
+	V3Files []metalinkFile `xml:"files>file"`
+}
+
+type metalinkFile struct {
+	Name     string         `xml:"name,attr"`
+	Size     int64          `xml:"size"`
+	Hashes   []metalinkHash `xml:"hash"`
+	V3Hashes []metalinkHash `xml:"verification>hash"`
+	URLs     []metalinkURL  `xml:"url"`
+	V3URLs   []metalinkURL  `xml:"resources>url"`
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type metalinkURL struct {
+	Priority   int    `xml:"priority,attr"`
+	Preference int    `xml:"preference,attr"`
+	Value      string `xml:",chardata"`
+}
+
+// LoadMetalink parses a .meta4/.metalink file into entries whose mirrors are
+// ordered by priority (Metalink 4: lowest first; 3.0: highest preference
+// first) and which carry the expected size and strongest supported hash.
+func LoadMetalink(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var doc metalinkDoc
+	if err := xml.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	files := append(doc.Files, doc.V3Files...)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no <file> elements", path)
+	}
+
+	out := make([]Entry, 0, len(files))
+	for _, file := range files {
+		entry, err := file.entry()
+		if err != nil {
+			return nil, fmt.Errorf("%s: file %q: %w", path, file.Name, err)
+		}
+		out = append(out, entry)
+	}
+	numberEntries(out)
+	return out, nil
+}
+
+func (f metalinkFile) entry() (Entry, error) {
+	if f.Name == "" {
+		return Entry{}, errors.New("missing name attribute")
+	}
+
+	v4 := len(f.URLs) > 0
+	links := append(f.URLs, f.V3URLs...)
+	sort.SliceStable(links, func(i, j int) bool {
+		if v4 {
+			return rank4(links[i].Priority) < rank4(links[j].Priority)
+		}
+		return links[i].Preference > links[j].Preference
+	})
+
+	var mirrors []string
+	for _, l := range links {
+		if u := strings.TrimSpace(l.Value); u != "" {
+			mirrors = append(mirrors, u)
+		}
+	}
+	if len(mirrors) == 0 {
+		return Entry{}, errors.New("no <url> elements")
+	}
+
+	return Entry{
+		Mirrors: mirrors,
+		Name:    f.Name,
+		Size:    f.Size,
+		Hash:    pickHash(append(f.Hashes, f.V3Hashes...)),
+	}, nil
+}
+
+// rank4 orders Metalink 4 priorities, treating an absent priority as the
+// lowest (RFC 5854 allows 1-999999, lower is preferred).
+func rank4(p int) int {
+	if p <= 0 {
+		return 1000000
+	}
+	return p
+}
+
+func pickHash(hashes []metalinkHash) Checksum {
+	byType := make(map[string]string, len(hashes))
+	for _, h := range hashes {
+		algo := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(h.Type)), "-", "")
+		byType[algo] = strings.ToLower(strings.TrimSpace(h.Value))
+	}
+	for _, algo := range hashPreference {
+		if v := byType[algo]; v != "" {
+			return Checksum{Algorithm: algo, Value: v}
+		}
+	}
+	return Checksum{}
+}
@@ -0,0 +1,89 @@
+package urls
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const meta4 = `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="image.iso">
+    <size>1024</size>
+    <hash type="md5">ABCDEF</hash>
+    <hash type="sha-256">0123abcd</hash>
+    <pieces length="512" type="sha-1"><hash>ffff</hash></pieces>
+    <url priority="2">https://b.example/image.iso</url>
+    <url>https://c.example/image.iso</url>
+    <url priority="1">https://a.example/image.iso</url>
+  </file>
+</metalink>`
+
+const metalink3 = `<?xml version="1.0" encoding="UTF-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="old.bin">
+      <size>10</size>
+      <verification><hash type="sha1">aa</hash></verification>
+      <resources>
+        <url type="http" preference="10">https://slow.example/old.bin</url>
+        <url type="http" preference="100">https://fast.example/old.bin</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadMetalink4(t *testing.T) {
+	entries, err := LoadEntries(writeFile(t, "image.meta4", meta4))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Entry{{
+		Mirrors: []string{
+			"https://a.example/image.iso",
+			"https://b.example/image.iso",
+			"https://c.example/image.iso",
+		},
+		Name:  "image.iso",
+		Size:  1024,
+		Hash:  Checksum{Algorithm: "sha256", Value: "0123abcd"},
+		Index: 1,
+	}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("expected %+v, got %+v", want, entries)
+	}
+}
+
+func TestLoadMetalink3(t *testing.T) {
+	entries, err := LoadMetalink(writeFile(t, "old.metalink", metalink3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.URL() != "https://fast.example/old.bin" {
+		t.Fatalf("expected highest preference first, got %v", e.Mirrors)
+	}
+	if e.Hash != (Checksum{Algorithm: "sha1", Value: "aa"}) || e.Size != 10 {
+		t.Fatalf("unexpected metadata: %+v", e)
+	}
+}
+
+func TestLoadMetalinkWithoutURLs(t *testing.T) {
+	body := `<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="x"></file></metalink>`
+	if _, err := LoadMetalink(writeFile(t, "x.meta4", body)); err == nil {
+		t.Fatalf("expected error for file without URLs")
+	}
+}
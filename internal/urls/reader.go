@@ -2,12 +2,61 @@ package urls
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"strings"
 )
 
+// Entry is one logical object that may be served by several equivalent
+// mirrors. Mirrors are listed in order of preference.
+type Entry struct {
+	Mirrors []string
+	// Name, Size and Hash are optional metadata supplied by richer
+	// sources such as Metalink; zero values mean unknown.
+	Name string
+	Size int64
+	Hash Checksum
+	// Index is the 1-based position of the entry in its list, or 0.
+	Index int
+}
+
+// URL returns the preferred mirror of the entry.
+func (e Entry) URL() string {
+	if len(e.Mirrors) == 0 {
+		return ""
+	}
+	return e.Mirrors[0]
+}
+
 // Load reads a list of URLs from a text file, ignoring blanks and comments.
+// Each entry is expanded with Expand; errors carry the offending line number.
+// Only the preferred mirror of each entry is returned; use LoadEntries to
+// keep the alternatives.
 func Load(path string) ([]string, error) {
+	entries, err := LoadEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.URL()
+	}
+	return out, nil
+}
+
+// LoadEntries reads a list file in which a line may name several
+// whitespace-separated mirrors of the same object:
+//
+//	https://eu.example.com/x.bin https://us.example.com/x.bin
+//
+// Templates are expanded per mirror and zipped together, so every mirror on
+// a line must expand to the same number of URLs. Metalink documents
+// (.meta4, .metalink) are delegated to LoadMetalink.
+func LoadEntries(path string) ([]Entry, error) {
+	if IsMetalink(path) {
+		return LoadMetalink(path)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -15,8 +64,10 @@ func Load(path string) ([]string, error) {
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
-	var out []string
+	var out []Entry
+	lineNo := 0
 	for scanner.Scan() {
+		lineNo++
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
@@ -24,10 +75,47 @@ func Load(path string) ([]string, error) {
 		if strings.HasPrefix(line, "#") {
 			continue
 		}
-		out = append(out, line)
+		entries, err := expandLine(line, MaxExpansion-len(out))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		out = append(out, entries...)
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
+	numberEntries(out)
+	return out, nil
+}
+
+// numberEntries assigns list positions once expansion is complete.
+func numberEntries(entries []Entry) {
+	for i := range entries {
+		entries[i].Index = i + 1
+	}
+}
+
+func expandLine(line string, limit int) ([]Entry, error) {
+	fields := strings.Fields(line)
+	var expanded [][]string
+	for _, field := range fields {
+		urls, err := Expand(field, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(expanded) > 0 && len(urls) != len(expanded[0]) {
+			return nil, fmt.Errorf("mirror %q expands to %d URLs, expected %d", field, len(urls), len(expanded[0]))
+		}
+		expanded = append(expanded, urls)
+	}
+
+	out := make([]Entry, len(expanded[0]))
+	for i := range out {
+		mirrors := make([]string, len(expanded))
+		for m := range expanded {
+			mirrors[m] = expanded[m][i]
+		}
+		out[i] = Entry{Mirrors: mirrors}
+	}
 	return out, nil
 }